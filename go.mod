@@ -10,6 +10,8 @@ require (
 	github.com/rs/zerolog v1.31.0
 	github.com/wdvxdr1123/go-silk v0.0.0-20220304095002-f67345df09ea
 	go.mau.fi/util v0.2.1
+	golang.org/x/image v0.15.0
+	gopkg.in/yaml.v3 v3.0.1
 	maunium.net/go/mautrix v0.16.2
 )
 
@@ -34,7 +36,6 @@ require (
 	golang.org/x/sys v0.15.0 // indirect
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 	maunium.net/go/mauflag v1.0.0 // indirect
 	maunium.net/go/maulogger/v2 v2.4.1 // indirect
 	modernc.org/libc v1.38.0 // indirect
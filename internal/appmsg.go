@@ -0,0 +1,327 @@
+package internal
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/duo/matrix-wechat/internal/database"
+	"github.com/duo/matrix-wechat/internal/wechat"
+
+	"github.com/gabriel-vasile/mimetype"
+	"maunium.net/go/mautrix/appservice"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+)
+
+const appThumbBlob = "thumb"
+
+func (p *Portal) convertWechatApp(source *User, msg *wechat.Event, intent *appservice.IntentAPI) *ConvertedMessage {
+	msgID := fmt.Sprint(msg.ID)
+	data := msg.Data.(*wechat.AppData)
+
+	appMsg, err := wechat.ParseAppMsg(data.Content)
+	if err != nil {
+		if len(data.Content) > 0 {
+			p.log.Debug().Msgf("Failed to parse app message %s, using summary: %v", msgID, err)
+		}
+		appMsg = &wechat.AppMsg{}
+	}
+	if len(appMsg.Title) == 0 {
+		appMsg.Title = data.Title
+	}
+	if len(appMsg.Description) == 0 {
+		appMsg.Description = data.Description
+	}
+	if len(appMsg.URL) == 0 {
+		appMsg.URL = data.URL
+	}
+	if len(appMsg.SourceName) == 0 {
+		appMsg.SourceName = data.Source
+	}
+
+	switch appMsg.Type {
+	case wechat.AppMsgFile:
+		if blob, ok := data.Blobs["file"]; ok && len(blob.Binary) > 0 {
+			if len(blob.Name) == 0 {
+				blob.Name = appMsg.Title
+			}
+			return p.convertWechatBlob(msgID, wechat.EventFile, blob, intent)
+		}
+		body := fmt.Sprintf("📎 **%s**", appMsg.Title)
+		if appMsg.Attach.TotalLen > 0 {
+			body += fmt.Sprintf(" (%s)", FormatSize(appMsg.Attach.TotalLen))
+		}
+		body += "\nThe file can only be downloaded in the WeChat app."
+		return p.makeAppMessage(intent, event.MsgNotice, body, nil)
+	case wechat.AppMsgTransfer:
+		return p.makeAppMessage(intent, event.MsgNotice, formatTransfer(appMsg), nil)
+	case wechat.AppMsgRedPacket:
+		body := fmt.Sprintf("🧧 **%s**", firstNonEmpty(appMsg.PayInfo.SceneText, "Red packet"))
+		if len(appMsg.Title) > 0 {
+			body += "\n" + appMsg.Title
+		}
+		body += "\nOpen it in the WeChat app."
+		return p.makeAppMessage(intent, event.MsgNotice, body, nil)
+	case wechat.AppMsgChatHistory:
+		return p.convertWechatChatHistory(intent, msgID, appMsg, data)
+	case wechat.AppMsgText, wechat.AppMsgQuote:
+		return p.makeAppMessage(intent, event.MsgText, appMsg.Title, nil)
+	}
+
+	var body string
+	switch appMsg.Type {
+	case wechat.AppMsgMiniProgram, wechat.AppMsgMiniApp:
+		body = fmt.Sprintf("**Mini Program: %s**\n%s", firstNonEmpty(appMsg.SourceName, appMsg.WeApp.Username), markdownLink(appMsg.Title, appMsg.URL))
+	case wechat.AppMsgMusic, wechat.AppMsgMusicCard:
+		body = "🎵 " + markdownLink(appMsg.Title, appMsg.URL)
+		if len(appMsg.Description) > 0 {
+			body += " - " + appMsg.Description
+		}
+		if len(appMsg.DataURL) > 0 {
+			body += fmt.Sprintf("\n[Play](%s)", appMsg.DataURL)
+		}
+	case wechat.AppMsgLink, wechat.AppMsgVideo, wechat.AppMsgChannels:
+		body = fmt.Sprintf("%s\n%s", markdownLink(appMsg.Title, appMsg.URL), appMsg.Description)
+	default:
+		if len(appMsg.Title) == 0 && len(appMsg.Description) == 0 && len(appMsg.URL) == 0 {
+			return p.makeAppMessage(intent, event.MsgNotice, fmt.Sprintf("Unsupported app message (type %d)", appMsg.Type), nil)
+		}
+		if len(appMsg.URL) > 0 {
+			body = fmt.Sprintf("[%s](%s)\n%s", appMsg.Title, appMsg.URL, appMsg.Description)
+		} else {
+			body = fmt.Sprintf("**%s**\n%s", appMsg.Title, appMsg.Description)
+		}
+	}
+
+	var extra map[string]interface{}
+	if preview := p.makeLinkPreview(intent, msgID, appMsg, data); preview != nil {
+		extra = map[string]interface{}{
+			"com.beeper.linkpreviews": []interface{}{preview},
+		}
+	}
+
+	return p.makeAppMessage(intent, event.MsgText, strings.TrimSpace(body), extra)
+}
+
+// convertWechatChatHistory expands a forwarded chat history bundle into a
+// quote block. Media of the bundle is sent as thread replies to the quote.
+func (p *Portal) convertWechatChatHistory(intent *appservice.IntentAPI, msgID string, appMsg *wechat.AppMsg, data *wechat.AppData) *ConvertedMessage {
+	record, err := wechat.ParseRecordInfo(appMsg.RecordItem)
+	if err != nil {
+		p.log.Debug().Msgf("Failed to parse chat history %s, using summary: %v", msgID, err)
+		body := fmt.Sprintf("**%s**", appMsg.Title)
+		for _, line := range strings.Split(strings.TrimSpace(appMsg.Description), "\n") {
+			body += "\n> " + line
+		}
+		return p.makeAppMessage(intent, event.MsgText, body, nil)
+	}
+
+	title := firstNonEmpty(record.Title, appMsg.Title, "Chat history")
+	converted := &ConvertedMessage{
+		Intent: intent,
+		Type:   event.EventMessage,
+	}
+
+	var plain, formatted strings.Builder
+	plain.WriteString(title)
+	formatted.WriteString("<b>" + html.EscapeString(title) + "</b>")
+	p.renderRecord(intent, msgID, record, data, converted, &plain, &formatted, 1)
+
+	converted.Content = &event.MessageEventContent{
+		MsgType:       event.MsgText,
+		Format:        event.FormatHTML,
+		Body:          plain.String(),
+		FormattedBody: formatted.String(),
+	}
+
+	return converted
+}
+
+func (p *Portal) renderRecord(intent *appservice.IntentAPI, msgID string, record *wechat.RecordInfo, data *wechat.AppData, converted *ConvertedMessage, plain, formatted *strings.Builder, depth int) {
+	prefix := strings.Repeat("> ", depth)
+
+	formatted.WriteString("<blockquote>")
+	for _, item := range record.Items {
+		fmt.Fprintf(plain, "\n%s%s %s:", prefix, item.SourceName, item.SourceTime)
+		fmt.Fprintf(formatted, "<p><b>%s</b> <sub>%s</sub><br>", html.EscapeString(item.SourceName), html.EscapeString(item.SourceTime))
+
+		if item.Type == wechat.RecordChatHistory && item.Nested != nil {
+			nestedTitle := firstNonEmpty(item.Nested.Title, item.Title, "Chat history")
+			fmt.Fprintf(plain, "\n%s%s", prefix, nestedTitle)
+			fmt.Fprintf(formatted, "%s</p>", html.EscapeString(nestedTitle))
+			p.renderRecord(intent, msgID, item.Nested, data, converted, plain, formatted, depth+1)
+			continue
+		}
+
+		text, link := p.describeRecordItem(intent, msgID, &item, data, converted)
+		for _, line := range strings.Split(text, "\n") {
+			fmt.Fprintf(plain, "\n%s%s", prefix, line)
+		}
+		escaped := strings.ReplaceAll(html.EscapeString(text), "\n", "<br>")
+		if len(link) > 0 {
+			fmt.Fprintf(plain, "\n%s%s", prefix, link)
+			escaped = fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(link), escaped)
+		}
+		formatted.WriteString(escaped + "</p>")
+	}
+	formatted.WriteString("</blockquote>")
+}
+
+// describeRecordItem returns the text shown for a chat history item. Media
+// blobs are converted and queued as thread replies of the bundle.
+func (p *Portal) describeRecordItem(intent *appservice.IntentAPI, msgID string, item *wechat.RecordItem, data *wechat.AppData, converted *ConvertedMessage) (string, string) {
+	var label string
+	var eventType wechat.EventType
+	switch item.Type {
+	case wechat.RecordText:
+		return item.Content, ""
+	case wechat.RecordLink:
+		return firstNonEmpty(item.Title, item.Content, item.Link), item.Link
+	case wechat.RecordLocation:
+		return "[Location] " + firstNonEmpty(item.Content, item.Title), ""
+	case wechat.RecordImage:
+		label, eventType = "[Image]", wechat.EventPhoto
+	case wechat.RecordVoice:
+		label, eventType = "[Voice]", wechat.EventAudio
+	case wechat.RecordVideo:
+		label, eventType = "[Video]", wechat.EventVideo
+	case wechat.RecordFile:
+		label, eventType = "[File] "+item.Title, wechat.EventFile
+		if item.Size > 0 {
+			label += fmt.Sprintf(" (%s)", FormatSize(item.Size))
+		}
+	default:
+		return firstNonEmpty(item.Content, item.Title, "[Unsupported message]"), ""
+	}
+
+	blob, ok := data.Blobs[item.DataID]
+	if !ok || len(blob.Binary) == 0 {
+		return label, ""
+	}
+	if len(blob.Name) == 0 {
+		blob.Name = firstNonEmpty(item.Title, item.DataID)
+	}
+	partID := database.PartMsgID(msgID, len(converted.MultiEvent)+1)
+	part := p.convertWechatBlob(partID, eventType, blob, intent)
+	part.Thread = true
+	converted.MultiEvent = append(converted.MultiEvent, part)
+
+	return label + " (in thread)", ""
+}
+
+func (p *Portal) makeAppMessage(intent *appservice.IntentAPI, msgType event.MessageType, body string, extra map[string]interface{}) *ConvertedMessage {
+	return &ConvertedMessage{
+		Intent: intent,
+		Type:   event.EventMessage,
+		Content: &event.MessageEventContent{
+			MsgType:       msgType,
+			Format:        event.FormatHTML,
+			Body:          body,
+			FormattedBody: format.RenderMarkdown(body, true, false).FormattedBody,
+		},
+		Extra: extra,
+	}
+}
+
+// makeLinkPreview uploads the card thumbnail and describes the card in the
+// link preview format understood by some Matrix clients.
+func (p *Portal) makeLinkPreview(intent *appservice.IntentAPI, msgID string, appMsg *wechat.AppMsg, data *wechat.AppData) map[string]interface{} {
+	if len(appMsg.URL) == 0 {
+		return nil
+	}
+
+	preview := map[string]interface{}{
+		"matched_url":    appMsg.URL,
+		"og:url":         appMsg.URL,
+		"og:title":       appMsg.Title,
+		"og:description": appMsg.Description,
+	}
+
+	thumb := p.getAppThumbnail(appMsg, data)
+	if thumb == nil {
+		return preview
+	}
+	content := &event.MessageEventContent{
+		Info: &event.FileInfo{MimeType: mimetype.Detect(thumb).String()},
+	}
+	if err := p.uploadMedia(intent, thumb, content); err != nil {
+		p.log.Warn().Msgf("Failed to upload thumbnail of %s: %v", msgID, err)
+		return preview
+	}
+	if content.File != nil {
+		preview["beeper:image:encryption"] = content.File
+	} else {
+		preview["og:image"] = content.URL
+	}
+	preview["og:image:type"] = content.Info.MimeType
+	preview["og:image:width"] = content.Info.Width
+	preview["og:image:height"] = content.Info.Height
+	preview["matrix:image:size"] = content.Info.Size
+
+	return preview
+}
+
+// getAppThumbnail returns the thumbnail sent by the agent, falling back to
+// downloading the thumbnail URL of the card.
+func (p *Portal) getAppThumbnail(appMsg *wechat.AppMsg, data *wechat.AppData) []byte {
+	if blob, ok := data.Blobs[appThumbBlob]; ok && len(blob.Binary) > 0 {
+		return blob.Binary
+	}
+	for _, blob := range data.Blobs {
+		if len(blob.Binary) > 0 && strings.HasPrefix(mimetype.Detect(blob.Binary).String(), "image/") {
+			return blob.Binary
+		}
+	}
+
+	thumbURL := firstNonEmpty(appMsg.ThumbURL, appMsg.WeApp.IconURL)
+	if len(thumbURL) == 0 {
+		return nil
+	}
+	thumb, err := GetBytes(thumbURL)
+	if err != nil {
+		p.log.Debug().Msgf("Failed to download app message thumbnail: %v", err)
+		return nil
+	}
+
+	return thumb
+}
+
+func formatTransfer(appMsg *wechat.AppMsg) string {
+	var status string
+	switch appMsg.PayInfo.SubType {
+	case wechat.TransferSent:
+		status = "Waiting to be received"
+	case wechat.TransferReceived:
+		status = "Received"
+	case wechat.TransferRefunded:
+		status = "Refunded"
+	default:
+		status = firstNonEmpty(appMsg.Description, "Transfer")
+	}
+
+	body := fmt.Sprintf("💰 **Transfer %s**: %s", appMsg.PayInfo.FeeDesc, status)
+	if len(appMsg.PayInfo.Memo) > 0 {
+		body += "\n" + appMsg.PayInfo.Memo
+	}
+
+	return body
+}
+
+func markdownLink(title, url string) string {
+	if len(url) == 0 {
+		return title
+	}
+
+	return fmt.Sprintf("[%s](%s)", title, url)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if len(value) > 0 {
+			return value
+		}
+	}
+
+	return ""
+}
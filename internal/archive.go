@@ -0,0 +1,258 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/appservice"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+)
+
+const (
+	ArchiveTag      = "tag"
+	ArchiveSubspace = "subspace"
+	ArchiveCleanup  = "cleanup"
+)
+
+var (
+	errNoPersonalSpace = errors.New("personal filtering spaces are disabled")
+	errNoDoublePuppet  = errors.New("double puppeting is required to tag rooms")
+	errPortalShared    = errors.New("portal is used by other Matrix users")
+)
+
+type archiveItem struct {
+	Portal      *Portal
+	LastMessage time.Time
+}
+
+type archivePlan struct {
+	Archive []archiveItem
+	Restore []archiveItem
+}
+
+// planPortalArchive finds the portals of the user which became inactive, and
+// the archived ones which are active again.
+func (u *User) planPortalArchive() *archivePlan {
+	archiveConfig := u.bridge.Config.Bridge.PortalArchive
+	plan := &archivePlan{}
+	if archiveConfig.InactiveDays <= 0 || u.UID.IsEmpty() {
+		return plan
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -archiveConfig.InactiveDays)
+	for _, activity := range u.bridge.DB.Portal.GetActivity(u.UID) {
+		if activity.Key.UID.IsUser() && !archiveConfig.PrivateChats {
+			continue
+		}
+		// The age of portals without any bridged message is unknown.
+		if activity.LastMessage.IsZero() {
+			continue
+		}
+
+		inactive := activity.LastMessage.Before(cutoff)
+		if inactive == u.IsArchived(activity.Key) {
+			continue
+		}
+		portal := u.bridge.GetPortalByUID(activity.Key)
+		if portal == nil || len(portal.MXID) == 0 {
+			continue
+		}
+
+		item := archiveItem{Portal: portal, LastMessage: activity.LastMessage}
+		if inactive {
+			plan.Archive = append(plan.Archive, item)
+		} else {
+			plan.Restore = append(plan.Restore, item)
+		}
+	}
+	sort.Slice(plan.Archive, func(i, j int) bool {
+		return plan.Archive[i].LastMessage.Before(plan.Archive[j].LastMessage)
+	})
+
+	return plan
+}
+
+func (u *User) applyPortalArchive(plan *archivePlan) (archived, restored int) {
+	for _, item := range plan.Archive {
+		if err := u.archivePortal(item.Portal); err != nil {
+			item.Portal.log.Warn().Msgf("Failed to archive portal for %s: %v", u.MXID, err)
+		} else {
+			archived++
+		}
+	}
+	for _, item := range plan.Restore {
+		if err := u.restorePortal(item.Portal); err != nil {
+			item.Portal.log.Warn().Msgf("Failed to restore archived portal for %s: %v", u.MXID, err)
+		} else {
+			restored++
+		}
+	}
+
+	return
+}
+
+func (u *User) archivePortal(portal *Portal) error {
+	switch action := u.bridge.Config.Bridge.PortalArchive.Action; action {
+	case ArchiveCleanup:
+		if !canDeletePortal(portal, u.MXID) {
+			return errPortalShared
+		}
+		portal.log.Info().Msgf("Deleting inactive portal of %s", u.MXID)
+		portal.Delete()
+		portal.Cleanup(false)
+		return nil
+	case ArchiveTag:
+		intent := u.customIntent()
+		if intent == nil {
+			return errNoDoublePuppet
+		}
+		if err := intent.AddTagWithCustomData(portal.MXID, u.bridge.Config.Bridge.PortalArchive.Tag, &event.Tag{}); err != nil {
+			return err
+		}
+	case ArchiveSubspace:
+		archiveID := u.GetArchiveSpace()
+		if len(archiveID) == 0 {
+			return errNoPersonalSpace
+		}
+		if err := u.setSpaceChild(archiveID, portal.MXID); err != nil {
+			return err
+		}
+		if err := u.removeSpaceChild(u.SpaceRoom, portal.MXID); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown archive action %q", action)
+	}
+
+	portal.log.Debug().Msgf("Archived inactive portal of %s", u.MXID)
+	u.SetArchived(portal.Key, true)
+
+	return nil
+}
+
+// restorePortal undoes both tagging and moving to the archive space, so
+// portals are restored properly after the archive action was changed.
+func (u *User) restorePortal(portal *Portal) error {
+	if intent := u.customIntent(); intent != nil {
+		if err := intent.RemoveTag(portal.MXID, u.bridge.Config.Bridge.PortalArchive.Tag); err != nil {
+			return err
+		}
+	}
+	if spaceID := u.GetSpaceRoom(); len(spaceID) > 0 {
+		if len(u.ArchiveSpace) > 0 {
+			if err := u.removeSpaceChild(u.ArchiveSpace, portal.MXID); err != nil {
+				return err
+			}
+		}
+		if err := u.setSpaceChild(spaceID, portal.MXID); err != nil {
+			return err
+		}
+	}
+
+	portal.log.Debug().Msgf("Restored archived portal of %s", u.MXID)
+	u.SetArchived(portal.Key, false)
+
+	return nil
+}
+
+// GetArchiveSpace returns the sub-space of the personal filtering space which
+// archived portals are moved to, creating it if necessary.
+func (u *User) GetArchiveSpace() id.RoomID {
+	spaceID := u.GetSpaceRoom()
+	if len(spaceID) == 0 {
+		return ""
+	}
+
+	u.spaceCreateLock.Lock()
+	defer u.spaceCreateLock.Unlock()
+	if len(u.ArchiveSpace) > 0 {
+		return u.ArchiveSpace
+	}
+
+	resp, err := u.bridge.Bot.CreateRoom(&mautrix.ReqCreateRoom{
+		Visibility: "private",
+		Name:       "WeChat Archive",
+		Topic:      "Your inactive WeChat bridged chats",
+		InitialState: []*event.Event{{
+			Type: event.StateRoomAvatar,
+			Content: event.Content{
+				Parsed: &event.RoomAvatarEventContent{
+					URL: u.bridge.Config.AppService.Bot.ParsedAvatar,
+				},
+			},
+		}},
+		CreationContent: map[string]interface{}{
+			"type": event.RoomTypeSpace,
+		},
+		PowerLevelOverride: &event.PowerLevelsEventContent{
+			Users: map[id.UserID]int{
+				u.bridge.Bot.UserID: 9001,
+				u.MXID:              50,
+			},
+		},
+	})
+	if err != nil {
+		u.log.Error().Msgf("Failed to create archive space room: %v", err)
+		return ""
+	}
+
+	u.ArchiveSpace = resp.RoomID
+	u.Update()
+	u.ensureInvited(u.bridge.Bot, u.ArchiveSpace, false)
+	if err = u.setSpaceChild(spaceID, u.ArchiveSpace); err != nil {
+		u.log.Warn().Msgf("Failed to add archive space to personal filtering space: %v", err)
+	}
+
+	return u.ArchiveSpace
+}
+
+func (u *User) setSpaceChild(spaceID, roomID id.RoomID) error {
+	_, err := u.bridge.Bot.SendStateEvent(spaceID, event.StateSpaceChild, roomID.String(), &event.SpaceChildEventContent{
+		Via: []string{u.bridge.Config.Homeserver.Domain},
+	})
+
+	return err
+}
+
+// customIntent returns the double puppet of the user, which is needed to
+// change account data like room tags.
+func (u *User) customIntent() *appservice.IntentAPI {
+	puppet := u.bridge.GetPuppetByCustomMXID(u.MXID)
+	if puppet == nil {
+		return nil
+	}
+
+	return puppet.CustomIntent()
+}
+
+func (u *User) removeSpaceChild(spaceID, roomID id.RoomID) error {
+	_, err := u.bridge.Bot.SendStateEvent(spaceID, event.StateSpaceChild, roomID.String(), struct{}{})
+
+	return err
+}
+
+func (br *WechatBridge) portalArchiveLoop() {
+	archiveConfig := br.Config.Bridge.PortalArchive
+	if archiveConfig.InactiveDays <= 0 || archiveConfig.CheckInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(archiveConfig.CheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for _, user := range br.GetAllUsers() {
+			if user == nil || user.UID.IsEmpty() {
+				continue
+			}
+			archived, restored := user.applyPortalArchive(user.planPortalArchive())
+			if archived > 0 || restored > 0 {
+				user.log.Info().Msgf("Archived %d and restored %d portals", archived, restored)
+			}
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package audio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/wdvxdr1123/go-silk"
+)
+
+const (
+	// SampleRate is the sample rate used for all intermediate PCM data.
+	SampleRate = 24000
+
+	silkGain        = 7.8125
+	jobTimeout      = 1 * time.Minute
+	waveformBuckets = 100
+)
+
+var (
+	ErrUnsupported = errors.New("operation not supported by audio backend")
+	// ErrNoBackend is returned for conversions which need ffmpeg when it isn't available.
+	ErrNoBackend = errors.New("ffmpeg is required to convert this audio")
+)
+
+type Format string
+
+const (
+	FormatOgg Format = "ogg"
+	FormatMP3 Format = "mp3"
+	FormatWAV Format = "wav"
+)
+
+func (f Format) MimeType() string {
+	switch f {
+	case FormatOgg:
+		return "audio/ogg"
+	case FormatMP3:
+		return "audio/mpeg"
+	case FormatWAV:
+		return "audio/wav"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// Backend converts between signed 16-bit little-endian mono PCM and container formats.
+type Backend interface {
+	Name() string
+	// Encode converts PCM to the given format.
+	Encode(ctx context.Context, pcm []byte, sampleRate int, format Format) ([]byte, error)
+	// Decode converts audio in any format the backend understands to PCM.
+	Decode(ctx context.Context, data []byte, sampleRate int) ([]byte, error)
+}
+
+// NewBackend returns the backend with the given name. `auto` picks ffmpeg if
+// it's available and otherwise returns a nil backend, see NewTranscoder.
+func NewBackend(name string) (Backend, error) {
+	switch name {
+	case "", "auto":
+		if path, err := exec.LookPath("ffmpeg"); err == nil {
+			return NewFFmpegBackend(path), nil
+		}
+		return nil, nil
+	case "ffmpeg":
+		path, err := exec.LookPath("ffmpeg")
+		if err != nil {
+			return nil, fmt.Errorf("ffmpeg backend requested but not found: %w", err)
+		}
+		return NewFFmpegBackend(path), nil
+	default:
+		return nil, fmt.Errorf("unknown audio backend %q", name)
+	}
+}
+
+// Voice is a converted voice message with the metadata Matrix clients need to render it.
+type Voice struct {
+	Data     []byte
+	Format   Format
+	Duration time.Duration
+	Waveform []int
+}
+
+// Transcoder runs audio conversions on a backend with a bounded number of concurrent jobs.
+// Without a backend, only SILK and WAV are supported: WeChat voice messages
+// become WAV files and everything else fails with ErrNoBackend.
+type Transcoder struct {
+	backend Backend
+	workers chan struct{}
+}
+
+func NewTranscoder(backend Backend, workers int) *Transcoder {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	return &Transcoder{
+		backend: backend,
+		workers: make(chan struct{}, workers),
+	}
+}
+
+func (t *Transcoder) Backend() Backend {
+	return t.backend
+}
+
+func (t *Transcoder) run(ctx context.Context, job func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, jobTimeout)
+	defer cancel()
+
+	select {
+	case t.workers <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-t.workers }()
+
+	return job(ctx)
+}
+
+// SilkToMatrix converts a WeChat SILK voice message to a format Matrix clients can play.
+// The result is Ogg/Opus, or WAV if there's no backend.
+func (t *Transcoder) SilkToMatrix(ctx context.Context, data []byte) (*Voice, error) {
+	voice := &Voice{}
+	err := t.run(ctx, func(ctx context.Context) error {
+		pcm, err := silk.DecodeSilkBuffToPcm(data, SampleRate)
+		if err != nil {
+			return fmt.Errorf("failed to decode silk: %w", err)
+		}
+		Amplify(pcm, silkGain)
+		voice.Duration = PCMDuration(pcm, SampleRate)
+		voice.Waveform = Waveform(pcm, waveformBuckets)
+
+		if t.backend == nil {
+			voice.Format, voice.Data = FormatWAV, EncodeWAV(pcm, SampleRate)
+			return nil
+		}
+		voice.Format = FormatOgg
+		voice.Data, err = t.backend.Encode(ctx, pcm, SampleRate, voice.Format)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return voice, nil
+}
+
+// MatrixToSilk converts a Matrix voice message to WeChat SILK and returns its duration in milliseconds.
+func (t *Transcoder) MatrixToSilk(ctx context.Context, data []byte) ([]byte, int64, error) {
+	var output []byte
+	var duration int64
+	err := t.run(ctx, func(ctx context.Context) error {
+		pcm, err := t.decode(ctx, data)
+		if err != nil {
+			return err
+		}
+
+		output, err = silk.EncodePcmBuffToSilk(pcm, SampleRate, SampleRate, true)
+		if err != nil {
+			return fmt.Errorf("failed to encode silk: %w", err)
+		}
+		duration = PCMDuration(pcm, SampleRate).Milliseconds()
+
+		return nil
+	})
+
+	return output, duration, err
+}
+
+// ToMP3 converts audio to mp3.
+func (t *Transcoder) ToMP3(ctx context.Context, data []byte) ([]byte, error) {
+	if t.backend == nil {
+		return nil, ErrNoBackend
+	}
+
+	var output []byte
+	err := t.run(ctx, func(ctx context.Context) error {
+		pcm, err := t.decode(ctx, data)
+		if err != nil {
+			return err
+		}
+
+		output, err = t.backend.Encode(ctx, pcm, SampleRate, FormatMP3)
+
+		return err
+	})
+
+	return output, err
+}
+
+// decode converts audio to PCM. WAV is decoded without the backend.
+func (t *Transcoder) decode(ctx context.Context, data []byte) ([]byte, error) {
+	if pcm, rate, err := DecodeWAV(data); err == nil {
+		return Resample(pcm, rate, SampleRate), nil
+	} else if t.backend == nil {
+		return nil, ErrNoBackend
+	}
+
+	return t.backend.Decode(ctx, data, SampleRate)
+}
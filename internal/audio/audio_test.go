@@ -0,0 +1,148 @@
+package audio
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/wdvxdr1123/go-silk"
+)
+
+// The fixtures are half a second of a 440 Hz tone: tone.silk at 24 kHz and
+// tone.wav at 8 kHz.
+const fixtureDuration = 500 * time.Millisecond
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("failed to read fixture %s: %v", name, err)
+	}
+
+	return data
+}
+
+func assertDuration(t *testing.T, name string, got, want time.Duration) {
+	t.Helper()
+
+	if diff := got - want; diff < -40*time.Millisecond || diff > 40*time.Millisecond {
+		t.Errorf("%s: duration %s, want %s", name, got, want)
+	}
+}
+
+func silkDuration(t *testing.T, data []byte) time.Duration {
+	t.Helper()
+
+	pcm, err := silk.DecodeSilkBuffToPcm(data, SampleRate)
+	if err != nil {
+		t.Fatalf("failed to decode silk: %v", err)
+	}
+
+	return PCMDuration(pcm, SampleRate)
+}
+
+func TestSilkRoundTrip(t *testing.T) {
+	pcm, err := silk.DecodeSilkBuffToPcm(readFixture(t, "tone.silk"), SampleRate)
+	if err != nil {
+		t.Fatalf("failed to decode silk: %v", err)
+	}
+	assertDuration(t, "decoded", PCMDuration(pcm, SampleRate), fixtureDuration)
+
+	encoded, err := silk.EncodePcmBuffToSilk(pcm, SampleRate, SampleRate, true)
+	if err != nil {
+		t.Fatalf("failed to encode silk: %v", err)
+	}
+	assertDuration(t, "re-encoded", silkDuration(t, encoded), fixtureDuration)
+}
+
+func TestWAVToSilk(t *testing.T) {
+	transcoder := NewTranscoder(nil, 1)
+
+	output, duration, err := transcoder.MatrixToSilk(context.Background(), readFixture(t, "tone.wav"))
+	if err != nil {
+		t.Fatalf("MatrixToSilk failed: %v", err)
+	}
+	assertDuration(t, "reported", time.Duration(duration)*time.Millisecond, fixtureDuration)
+	assertDuration(t, "encoded", silkDuration(t, output), fixtureDuration)
+}
+
+func TestSilkToMatrixWithoutBackend(t *testing.T) {
+	transcoder := NewTranscoder(nil, 1)
+
+	voice, err := transcoder.SilkToMatrix(context.Background(), readFixture(t, "tone.silk"))
+	if err != nil {
+		t.Fatalf("SilkToMatrix failed: %v", err)
+	}
+	if voice.Format != FormatWAV {
+		t.Errorf("format %s, want %s", voice.Format, FormatWAV)
+	}
+	assertDuration(t, "voice", voice.Duration, fixtureDuration)
+
+	pcm, rate, err := DecodeWAV(voice.Data)
+	if err != nil {
+		t.Fatalf("output is not valid wav: %v", err)
+	}
+	assertDuration(t, "wav", PCMDuration(pcm, rate), fixtureDuration)
+}
+
+func TestWithoutBackend(t *testing.T) {
+	transcoder := NewTranscoder(nil, 1)
+	ctx := context.Background()
+
+	if _, _, err := transcoder.MatrixToSilk(ctx, []byte("OggS\x00\x02")); !errors.Is(err, ErrNoBackend) {
+		t.Errorf("MatrixToSilk: got error %v, want ErrNoBackend", err)
+	}
+	if _, err := transcoder.ToMP3(ctx, readFixture(t, "tone.wav")); !errors.Is(err, ErrNoBackend) {
+		t.Errorf("ToMP3: got error %v, want ErrNoBackend", err)
+	}
+	if _, err := NewBackend("native"); err == nil {
+		t.Error("NewBackend accepted an unknown backend")
+	}
+}
+
+func newFFmpegTranscoder(t *testing.T) *Transcoder {
+	t.Helper()
+
+	path, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		t.Skip("ffmpeg not found")
+	}
+
+	return NewTranscoder(NewFFmpegBackend(path), 1)
+}
+
+func TestFFmpegRoundTrip(t *testing.T) {
+	transcoder := newFFmpegTranscoder(t)
+	ctx := context.Background()
+
+	voice, err := transcoder.SilkToMatrix(ctx, readFixture(t, "tone.silk"))
+	if err != nil {
+		t.Fatalf("SilkToMatrix failed: %v", err)
+	}
+	if voice.Format != FormatOgg {
+		t.Errorf("format %s, want %s", voice.Format, FormatOgg)
+	}
+	assertDuration(t, "voice", voice.Duration, fixtureDuration)
+
+	output, duration, err := transcoder.MatrixToSilk(ctx, voice.Data)
+	if err != nil {
+		t.Fatalf("MatrixToSilk failed: %v", err)
+	}
+	assertDuration(t, "reported", time.Duration(duration)*time.Millisecond, fixtureDuration)
+	assertDuration(t, "encoded", silkDuration(t, output), fixtureDuration)
+}
+
+func TestFFmpegToMP3(t *testing.T) {
+	transcoder := newFFmpegTranscoder(t)
+
+	output, err := transcoder.ToMP3(context.Background(), readFixture(t, "tone.wav"))
+	if err != nil {
+		t.Fatalf("ToMP3 failed: %v", err)
+	} else if len(output) == 0 {
+		t.Error("ToMP3 returned no data")
+	}
+}
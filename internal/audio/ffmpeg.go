@@ -0,0 +1,60 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// FFmpegBackend pipes audio through ffmpeg over stdin/stdout, without temporary files.
+type FFmpegBackend struct {
+	path string
+}
+
+func NewFFmpegBackend(path string) *FFmpegBackend {
+	return &FFmpegBackend{path: path}
+}
+
+func (fb *FFmpegBackend) Name() string {
+	return "ffmpeg"
+}
+
+func (fb *FFmpegBackend) Encode(ctx context.Context, pcm []byte, sampleRate int, format Format) ([]byte, error) {
+	args := []string{"-f", "s16le", "-ar", strconv.Itoa(sampleRate), "-ac", "1", "-i", "pipe:0"}
+	switch format {
+	case FormatOgg:
+		args = append(args, "-c:a", "libopus", "-b:a", "24K", "-f", "ogg")
+	case FormatMP3:
+		args = append(args, "-f", "mp3")
+	case FormatWAV:
+		return EncodeWAV(pcm, sampleRate), nil
+	default:
+		return nil, fmt.Errorf("%w: encoding %s", ErrUnsupported, format)
+	}
+
+	return fb.run(ctx, pcm, append(args, "pipe:1")...)
+}
+
+func (fb *FFmpegBackend) Decode(ctx context.Context, data []byte, sampleRate int) ([]byte, error) {
+	return fb.run(ctx, data, "-i", "pipe:0", "-f", "s16le", "-ar", strconv.Itoa(sampleRate), "-ac", "1", "pipe:1")
+}
+
+func (fb *FFmpegBackend) run(ctx context.Context, input []byte, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, fb.path, append([]string{"-hide_banner", "-loglevel", "error"}, args...)...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); len(msg) > 0 {
+			return nil, fmt.Errorf("ffmpeg failed: %w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("ffmpeg failed: %w", err)
+	}
+
+	return stdout.Bytes(), nil
+}
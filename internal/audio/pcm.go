@@ -0,0 +1,58 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// Amplify scales signed 16-bit little-endian PCM samples in place, clipping at the sample range.
+func Amplify(pcm []byte, gain float64) {
+	for i := 0; i+1 < len(pcm); i += 2 {
+		sample := float64(int16(binary.LittleEndian.Uint16(pcm[i:]))) * gain
+		sample = math.Max(math.MinInt16, math.Min(math.MaxInt16, sample))
+		binary.LittleEndian.PutUint16(pcm[i:], uint16(int16(sample)))
+	}
+}
+
+// PCMDuration returns the play time of signed 16-bit mono PCM.
+func PCMDuration(pcm []byte, sampleRate int) time.Duration {
+	samples := int64(len(pcm) / 2)
+
+	return time.Duration(samples) * time.Second / time.Duration(sampleRate)
+}
+
+// Waveform returns the peak amplitudes of signed 16-bit PCM in the given number of buckets,
+// scaled to the 0-1024 range used by MSC1767 voice messages.
+func Waveform(pcm []byte, buckets int) []int {
+	samples := len(pcm) / 2
+	if samples == 0 || buckets <= 0 {
+		return []int{}
+	}
+	if samples < buckets {
+		buckets = samples
+	}
+
+	peaks := make([]float64, buckets)
+	var max float64
+	for i := 0; i < samples; i++ {
+		sample := math.Abs(float64(int16(binary.LittleEndian.Uint16(pcm[i*2:]))))
+		bucket := i * buckets / samples
+		if sample > peaks[bucket] {
+			peaks[bucket] = sample
+		}
+		if sample > max {
+			max = sample
+		}
+	}
+
+	waveform := make([]int, buckets)
+	if max == 0 {
+		return waveform
+	}
+	for i, peak := range peaks {
+		waveform[i] = int(peak / max * 1024)
+	}
+
+	return waveform
+}
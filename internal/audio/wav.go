@@ -0,0 +1,112 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var errInvalidWAV = errors.New("invalid wav data")
+
+// EncodeWAV wraps signed 16-bit mono PCM in a RIFF/WAVE container.
+func EncodeWAV(pcm []byte, sampleRate int) []byte {
+	var buf bytes.Buffer
+	buf.Grow(44 + len(pcm))
+
+	buf.WriteString("RIFF")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(36+len(pcm)))
+	buf.WriteString("WAVEfmt ")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(16))           // chunk size
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(1))            // PCM
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(1))            // mono
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))   // sample rate
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(sampleRate*2)) // byte rate
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(2))            // block align
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(16))           // bits per sample
+	buf.WriteString("data")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(len(pcm)))
+	buf.Write(pcm)
+
+	return buf.Bytes()
+}
+
+// DecodeWAV extracts signed 16-bit PCM from a RIFF/WAVE container, mixing down to mono.
+func DecodeWAV(data []byte) ([]byte, int, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, 0, errInvalidWAV
+	}
+
+	var channels, bits int
+	var sampleRate int
+	for offset := 12; offset+8 <= len(data); {
+		id := string(data[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(data[offset+4:]))
+		body := data[offset+8:]
+		if size > len(body) {
+			size = len(body)
+		}
+		body = body[:size]
+
+		switch id {
+		case "fmt ":
+			if size < 16 || binary.LittleEndian.Uint16(body) != 1 {
+				return nil, 0, fmt.Errorf("%w: only PCM is supported", errInvalidWAV)
+			}
+			channels = int(binary.LittleEndian.Uint16(body[2:]))
+			sampleRate = int(binary.LittleEndian.Uint32(body[4:]))
+			bits = int(binary.LittleEndian.Uint16(body[14:]))
+		case "data":
+			if channels == 0 || bits != 16 {
+				return nil, 0, fmt.Errorf("%w: only 16-bit PCM is supported", errInvalidWAV)
+			}
+			return mixDown(body, channels), sampleRate, nil
+		}
+
+		offset += 8 + size + size%2
+	}
+
+	return nil, 0, fmt.Errorf("%w: missing data chunk", errInvalidWAV)
+}
+
+func mixDown(pcm []byte, channels int) []byte {
+	if channels == 1 {
+		return pcm
+	}
+
+	frame := channels * 2
+	output := make([]byte, len(pcm)/frame*2)
+	for i := 0; i+frame <= len(pcm); i += frame {
+		var sum int
+		for c := 0; c < channels; c++ {
+			sum += int(int16(binary.LittleEndian.Uint16(pcm[i+c*2:])))
+		}
+		binary.LittleEndian.PutUint16(output[i/channels:], uint16(int16(sum/channels)))
+	}
+
+	return output
+}
+
+// Resample converts signed 16-bit mono PCM to another sample rate using linear interpolation.
+func Resample(pcm []byte, from, to int) []byte {
+	if from == to || from <= 0 || to <= 0 {
+		return pcm
+	}
+
+	samples := len(pcm) / 2
+	outSamples := int(int64(samples) * int64(to) / int64(from))
+	output := make([]byte, outSamples*2)
+	for i := 0; i < outSamples; i++ {
+		pos := float64(i) * float64(from) / float64(to)
+		idx := int(pos)
+		frac := pos - float64(idx)
+		a := float64(int16(binary.LittleEndian.Uint16(pcm[idx*2:])))
+		b := a
+		if idx+1 < samples {
+			b = float64(int16(binary.LittleEndian.Uint16(pcm[(idx+1)*2:])))
+		}
+		binary.LittleEndian.PutUint16(output[i*2:], uint16(int16(a+(b-a)*frac)))
+	}
+
+	return output
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -36,9 +37,19 @@ func (br *WechatBridge) RegisterCommands() {
 		cmdPing,
 		cmdDeletePortal,
 		cmdDeleteAllPortals,
+		cmdPrunePortals,
 		cmdList,
 		cmdSearch,
 		cmdSync,
+		cmdBridge,
+		cmdUnbridge,
+		cmdSetRelay,
+		cmdUnsetRelay,
+		cmdSetFormatting,
+		cmdAccept,
+		cmdReject,
+		cmdPM,
+		cmdJoin,
 	)
 }
 
@@ -58,10 +69,13 @@ var (
 	HelpSectionConnectionManagement = commands.HelpSection{Name: "Connection management", Order: 11}
 	HelpSectionCreatingPortals      = commands.HelpSection{Name: "Creating portals", Order: 15}
 	HelpSectionPortalManagement     = commands.HelpSection{Name: "Portal management", Order: 20}
+	HelpSectionFriendRequests       = commands.HelpSection{Name: "Friend requests", Order: 24}
 	HelpSectionInvites              = commands.HelpSection{Name: "Group invites", Order: 25}
 	HelpSectionMiscellaneous        = commands.HelpSection{Name: "Miscellaneous", Order: 30}
 )
 
+var roomModerator = event.Type{Type: "me.lxduo.wechat.room_moderator", Class: event.StateEventType}
+
 var cmdLogin = &commands.FullHandler{
 	Func: wrapCommand(fnLogin),
 	Name: "login",
@@ -324,6 +338,61 @@ func formatGroups(input []*wechat.GroupInfo, query string) (result []string) {
 	return
 }
 
+var cmdPrunePortals = &commands.FullHandler{
+	Func: wrapCommand(fnPrunePortals),
+	Name: "prune-portals",
+	Help: commands.HelpMeta{
+		Section:     HelpSectionPortalManagement,
+		Description: "Archive portals without recent activity as configured. Use `--dry-run` to only list them.",
+		Args:        "[--dry-run]",
+	},
+	RequiresLogin: true,
+}
+
+func fnPrunePortals(ce *WrappedCommandEvent) {
+	archiveConfig := ce.Bridge.Config.Bridge.PortalArchive
+	if archiveConfig.InactiveDays <= 0 {
+		ce.Reply("Portal archiving is not enabled on this instance of the bridge")
+		return
+	}
+	dryRun := len(ce.Args) > 0 && ce.Args[0] == "--dry-run"
+
+	plan := ce.User.planPortalArchive()
+	if len(plan.Archive) == 0 && len(plan.Restore) == 0 {
+		ce.Reply("No portals have been inactive for more than %d days", archiveConfig.InactiveDays)
+		return
+	}
+
+	if !dryRun {
+		archived, restored := ce.User.applyPortalArchive(plan)
+		ce.Reply("Archived %d and restored %d portals", archived, restored)
+		return
+	}
+
+	verb := "archive"
+	if archiveConfig.Action == ArchiveCleanup {
+		verb = "delete"
+	}
+	formatItems := func(items []archiveItem) (result []string) {
+		for _, item := range items {
+			result = append(result, fmt.Sprintf("* [%s](https://matrix.to/#/%s) - last message on %s",
+				firstNonEmpty(item.Portal.Name, item.Portal.Key.UID.Uin), item.Portal.MXID, item.LastMessage.Format("2006-01-02")))
+		}
+		return
+	}
+
+	var result []string
+	if len(plan.Archive) > 0 {
+		result = append(result, fmt.Sprintf("Would %s %d portals inactive for more than %d days:\n\n%s",
+			verb, len(plan.Archive), archiveConfig.InactiveDays, strings.Join(formatItems(plan.Archive), "\n")))
+	}
+	if len(plan.Restore) > 0 {
+		result = append(result, fmt.Sprintf("Would restore %d archived portals which are active again:\n\n%s",
+			len(plan.Restore), strings.Join(formatItems(plan.Restore), "\n")))
+	}
+	ce.Reply(strings.Join(result, "\n\n"))
+}
+
 var cmdList = &commands.FullHandler{
 	Func: wrapCommand(fnList),
 	Name: "list",
@@ -495,3 +564,381 @@ func fnSync(ce *WrappedCommandEvent) {
 		}
 	}
 }
+
+var cmdPM = &commands.FullHandler{
+	Func: wrapCommand(fnPM),
+	Name: "pm",
+	Help: commands.HelpMeta{
+		Section:     HelpSectionCreatingPortals,
+		Description: "Open a private chat with a WeChat user. Users who aren't contacts yet can be sent a friend request with `--add`.",
+		Args:        "<_wxid_|_phone number_|_name_> [--add [_greeting_]]",
+	},
+	RequiresLogin: true,
+}
+
+func fnPM(ce *WrappedCommandEvent) {
+	args := ce.Args
+	var add bool
+	var greeting string
+	for i, arg := range args {
+		if arg == "--add" {
+			add = true
+			greeting = strings.Join(args[i+1:], " ")
+			args = args[:i]
+			break
+		}
+	}
+
+	query := strings.TrimSpace(strings.Join(args, " "))
+	if len(query) == 0 {
+		ce.Reply("**Usage:** `pm <wxid|phone number|name> [--add [greeting]]`")
+		return
+	}
+
+	contact, isFriend, candidates := resolveContact(ce.User, query)
+	if len(candidates) > 1 {
+		ce.Reply("Multiple contacts match `%s`, please use the wxid:\n\n%s",
+			query, strings.Join(formatContacts(ce.Bridge, candidates, ""), "\n"))
+		return
+	} else if contact == nil || len(contact.ID) == 0 {
+		ce.Reply("No WeChat user found for `%s`", query)
+		return
+	}
+
+	name := firstNonEmpty(contact.Remark, contact.Name, contact.ID)
+	if !isFriend {
+		if !add {
+			ce.Reply("%s is not your contact yet, use `pm %s --add [greeting]` to send a friend request", name, contact.ID)
+			return
+		} else if err := ce.User.Client.SendFriendRequest(contact.ID, greeting); err != nil {
+			ce.Reply("Failed to send friend request to %s: %v", name, err)
+			return
+		} else {
+			ce.Reply("Sent friend request to %s", name)
+		}
+	} else if add {
+		ce.Reply("%s is already your contact", name)
+	}
+
+	portal, puppet, justCreated, err := ce.User.StartPM(types.NewUserUID(contact.ID), "pm command")
+	if err != nil {
+		ce.Reply("Failed to create private chat portal: %v", err)
+	} else if justCreated {
+		ce.Reply("Created private chat portal with [%s](https://matrix.to/#/%s)", puppet.Displayname, portal.MXID)
+	} else {
+		ce.Reply("You already have a private chat portal with [%s](https://matrix.to/#/%s)", puppet.Displayname, portal.MXID)
+	}
+}
+
+// resolveContact finds a user in the friend list by wxid, remark or name, and
+// falls back to searching through the agent for users who aren't contacts.
+// Ambiguous names are returned as candidates instead.
+func resolveContact(user *User, query string) (*wechat.UserInfo, bool, []*wechat.UserInfo) {
+	friends := user.Client.GetFriendList()
+	lowerQuery := strings.ToLower(query)
+
+	var exact, partial []*wechat.UserInfo
+	for _, friend := range friends {
+		if friend.ID == query {
+			return friend, true, nil
+		}
+		if strings.EqualFold(friend.Remark, query) || strings.EqualFold(friend.Name, query) {
+			exact = append(exact, friend)
+		} else if matchesQuery(friend.Remark, lowerQuery) || matchesQuery(friend.Name, lowerQuery) {
+			partial = append(partial, friend)
+		}
+	}
+	for _, matches := range [][]*wechat.UserInfo{exact, partial} {
+		if len(matches) == 1 {
+			return matches[0], true, nil
+		} else if len(matches) > 1 {
+			return nil, true, matches
+		}
+	}
+
+	found := user.Client.SearchContact(query)
+	if found == nil {
+		return nil, false, nil
+	}
+	for _, friend := range friends {
+		if friend.ID == found.ID {
+			return friend, true, nil
+		}
+	}
+
+	return found, false, nil
+}
+
+var cmdBridge = &commands.FullHandler{
+	Func: wrapCommand(fnBridge),
+	Name: "bridge",
+	Help: commands.HelpMeta{
+		Section:     HelpSectionCreatingPortals,
+		Description: "Bridge the current Matrix room to an existing WeChat group.",
+		Args:        "<_group id_>",
+	},
+	RequiresLogin:      true,
+	RequiresEventLevel: roomModerator,
+}
+
+func fnBridge(ce *WrappedCommandEvent) {
+	if ce.Portal != nil {
+		ce.Reply("This is already a portal room. Use `unbridge` first if you want to link it to another group.")
+		return
+	} else if len(ce.Args) == 0 {
+		ce.Reply("**Usage:** `bridge <group id>`")
+		return
+	}
+
+	groupID := ce.Args[0]
+	if !strings.HasSuffix(groupID, "@chatroom") {
+		ce.Reply("`%s` is not a valid WeChat group ID", groupID)
+		return
+	}
+
+	portal := ce.User.GetPortalByUID(types.NewGroupUID(groupID))
+	if len(portal.MXID) > 0 {
+		ce.Reply("Group `%s` is already bridged to [%[2]s](https://matrix.to/#/%[2]s)", groupID, portal.MXID)
+		return
+	}
+
+	groupInfo := ce.User.Client.GetGroupInfo(groupID)
+	if groupInfo == nil {
+		ce.Reply("Failed to get info of group `%s`", groupID)
+		return
+	}
+	members := ce.User.Client.GetGroupMembers(groupID)
+	if !slices.Contains(members, ce.User.UID.Uin) {
+		ce.Reply("You are not a member of group `%s`", groupID)
+		return
+	}
+	groupInfo.Members = members
+
+	if err := ce.Bot.EnsureJoined(ce.RoomID); err != nil {
+		ce.Reply("Failed to join room as bridge bot: %v", err)
+		return
+	}
+
+	if err := portal.BridgeMatrixRoom(ce.User, ce.RoomID, groupInfo); err != nil {
+		ce.Reply("Failed to bridge room: %v", err)
+		return
+	}
+
+	ce.Reply("Successfully bridged room to group `%s`", groupID)
+}
+
+var cmdUnbridge = &commands.FullHandler{
+	Func: wrapCommand(fnUnbridge),
+	Name: "unbridge",
+	Help: commands.HelpMeta{
+		Section:     HelpSectionPortalManagement,
+		Description: "Remove the bridge between the current room and its WeChat group, keeping the Matrix room.",
+	},
+	RequiresPortal:     true,
+	RequiresEventLevel: roomModerator,
+}
+
+func fnUnbridge(ce *WrappedCommandEvent) {
+	if !ce.Portal.IsGroupChat() {
+		ce.Reply("Only group portals can be unbridged")
+		return
+	}
+
+	ce.Portal.log.Info().Msgf("%s requested unbridging of portal.", ce.User.MXID)
+	ce.Reply("Unbridging room from group `%[1]s`. Messages from the group won't be bridged until you use `bridge %[1]s` in a room again.", ce.Portal.Key.UID.Uin)
+	ce.Portal.Unbridge()
+}
+
+var cmdSetRelay = &commands.FullHandler{
+	Func: wrapCommand(fnSetRelay),
+	Name: "set-relay",
+	Help: commands.HelpMeta{
+		Section:     HelpSectionPortalManagement,
+		Description: "Relay messages in this room through your WeChat account.",
+	},
+	RequiresPortal: true,
+	RequiresLogin:  true,
+}
+
+func fnSetRelay(ce *WrappedCommandEvent) {
+	if !ce.Bridge.Config.Bridge.Relay.Enabled {
+		ce.Reply("Relay mode is not enabled on this instance of the bridge")
+	} else if ce.Bridge.Config.Bridge.Relay.AdminOnly && !ce.User.Admin {
+		ce.Reply("Only admins are allowed to enable relay mode on this instance of the bridge")
+	} else if !ce.Portal.IsGroupChat() {
+		ce.Reply("Relay mode can only be enabled in group portals")
+	} else {
+		ce.Portal.SetRelayUser(ce.User)
+		ce.Reply("Messages from non-logged-in users in this room will now be bridged through your WeChat account")
+	}
+}
+
+var cmdUnsetRelay = &commands.FullHandler{
+	Func: wrapCommand(fnUnsetRelay),
+	Name: "unset-relay",
+	Help: commands.HelpMeta{
+		Section:     HelpSectionPortalManagement,
+		Description: "Stop relaying messages in this room.",
+	},
+	RequiresPortal: true,
+}
+
+func fnUnsetRelay(ce *WrappedCommandEvent) {
+	if !ce.Bridge.Config.Bridge.Relay.Enabled {
+		ce.Reply("Relay mode is not enabled on this instance of the bridge")
+	} else if ce.Bridge.Config.Bridge.Relay.AdminOnly && !ce.User.Admin {
+		ce.Reply("Only admins are allowed to disable relay mode on this instance of the bridge")
+	} else if len(ce.Portal.RelayUserID) == 0 {
+		ce.Reply("This room doesn't have a relay user")
+	} else if ce.Portal.RelayUserID != ce.User.MXID && !ce.User.Admin {
+		ce.Reply("Only the relay user or bridge admins can disable relay mode")
+	} else {
+		ce.Portal.SetRelayUser(nil)
+		ce.Reply("Messages from non-logged-in users will no longer be bridged in this room")
+	}
+}
+
+var cmdSetFormatting = &commands.FullHandler{
+	Func: wrapCommand(fnSetFormatting),
+	Name: "set-formatting",
+	Help: commands.HelpMeta{
+		Section:     HelpSectionPortalManagement,
+		Description: "Choose how Matrix formatting is sent to WeChat in this room.",
+		Args:        "<plain|markup|default>",
+	},
+	RequiresPortal:     true,
+	RequiresEventLevel: roomModerator,
+}
+
+func fnSetFormatting(ce *WrappedCommandEvent) {
+	if len(ce.Args) != 1 {
+		ce.Reply("**Usage:** `set-formatting <plain|markup|default>` (currently `%s`)", ce.Portal.formattingMode())
+		return
+	}
+
+	switch mode := strings.ToLower(ce.Args[0]); mode {
+	case FormattingPlain, FormattingMarkup:
+		ce.Portal.Formatting = mode
+	case "default":
+		ce.Portal.Formatting = ""
+	default:
+		ce.Reply("Unknown formatting mode `%s`, use `plain`, `markup` or `default`", ce.Args[0])
+		return
+	}
+	ce.Portal.Update(nil)
+	ce.Reply("Formatting of messages sent to WeChat is now `%s`", ce.Portal.formattingMode())
+}
+
+var cmdAccept = &commands.FullHandler{
+	Func: wrapCommand(fnAccept),
+	Name: "accept",
+	Help: commands.HelpMeta{
+		Section:     HelpSectionFriendRequests,
+		Description: "Accept a friend request and open a private chat with the new contact.",
+		Args:        "<_request ID_>",
+	},
+	RequiresLogin: true,
+}
+
+func fnAccept(ce *WrappedCommandEvent) {
+	if len(ce.Args) != 1 {
+		ce.Reply("**Usage:** `accept <request ID>`")
+		return
+	}
+
+	info, err := ce.User.Client.AcceptFriendRequest(ce.Args[0])
+	if err != nil {
+		ce.Reply("Failed to accept friend request: %v", err)
+		return
+	}
+	request := ce.User.popFriendRequest(ce.Args[0])
+
+	var uid string
+	if info != nil {
+		uid = info.ID
+	} else if request != nil {
+		uid = request.UserID
+	}
+	if len(uid) == 0 {
+		ce.Reply("Friend request accepted")
+		return
+	}
+
+	portal, puppet, justCreated, err := ce.User.StartPM(types.NewUserUID(uid), "friend request accepted")
+	if err != nil {
+		ce.Reply("Friend request accepted, but failed to create private chat portal: %v", err)
+	} else if justCreated {
+		ce.Reply("Friend request accepted, created private chat portal with [%s](https://matrix.to/#/%s)", puppet.Displayname, portal.MXID)
+	} else {
+		ce.Reply("Friend request accepted, you already have a private chat portal with [%s](https://matrix.to/#/%s)", puppet.Displayname, portal.MXID)
+	}
+}
+
+var cmdReject = &commands.FullHandler{
+	Func: wrapCommand(fnReject),
+	Name: "reject",
+	Help: commands.HelpMeta{
+		Section:     HelpSectionFriendRequests,
+		Description: "Reject a friend request.",
+		Args:        "<_request ID_>",
+	},
+	RequiresLogin: true,
+}
+
+func fnReject(ce *WrappedCommandEvent) {
+	if len(ce.Args) != 1 {
+		ce.Reply("**Usage:** `reject <request ID>`")
+		return
+	}
+
+	if err := ce.User.Client.RejectFriendRequest(ce.Args[0]); err != nil {
+		ce.Reply("Failed to reject friend request: %v", err)
+		return
+	}
+	ce.User.popFriendRequest(ce.Args[0])
+
+	ce.Reply("Friend request rejected")
+}
+
+var cmdJoin = &commands.FullHandler{
+	Func: wrapCommand(fnJoin),
+	Name: "join",
+	Help: commands.HelpMeta{
+		Section:     HelpSectionInvites,
+		Description: "Accept a group invite and create a portal for the group.",
+		Args:        "<_invite ID_>",
+	},
+	RequiresLogin: true,
+}
+
+func fnJoin(ce *WrappedCommandEvent) {
+	if len(ce.Args) != 1 {
+		ce.Reply("**Usage:** `join <invite ID>`")
+		return
+	}
+
+	groupInfo, err := ce.User.Client.JoinGroup(ce.Args[0])
+	if err != nil {
+		ce.Reply("Failed to join group: %v", err)
+		return
+	}
+	invite := ce.User.popGroupInvite(ce.Args[0])
+
+	if groupInfo == nil && invite != nil && len(invite.GroupID) > 0 {
+		groupInfo = ce.User.Client.GetGroupInfo(invite.GroupID)
+	}
+	if groupInfo == nil || len(groupInfo.ID) == 0 {
+		ce.Reply("Joined group, the portal will be created when the first message arrives")
+		return
+	}
+
+	portal := ce.User.GetPortalByUID(types.NewGroupUID(groupInfo.ID))
+	if len(portal.MXID) > 0 {
+		portal.UpdateMatrixRoom(ce.User, groupInfo, false)
+		ce.Reply("Joined group, portal is [%[1]s](https://matrix.to/#/%[1]s)", portal.MXID)
+	} else if err := portal.CreateMatrixRoom(ce.User, groupInfo, false); err != nil {
+		ce.Reply("Joined group, but failed to create portal: %v", err)
+	} else {
+		ce.Reply("Joined group, created portal [%[1]s](https://matrix.to/#/%[1]s)", portal.MXID)
+	}
+}
@@ -25,6 +25,9 @@ type BridgeConfig struct {
 	ListenAddress       string `yaml:"listen_address"`
 	ListenSecret        string `yaml:"listen_secret"`
 
+	RoomDisplaynameTemplate   string `yaml:"room_displayname_template"`
+	RemarkDisplaynameTemplate string `yaml:"remark_displayname_template"`
+
 	PersonalFilteringSpaces bool `yaml:"personal_filtering_spaces"`
 
 	MessageStatusEvents bool `yaml:"message_status_events"`
@@ -33,6 +36,13 @@ type BridgeConfig struct {
 
 	AllowRedaction bool `yaml:"allow_redaction"`
 
+	OutboundFormatting string `yaml:"outbound_formatting"`
+
+	Emoticons struct {
+		Outbound  string            `yaml:"outbound"`
+		Overrides map[string]string `yaml:"overrides"`
+	} `yaml:"emoticons"`
+
 	UserAvatarSync bool `yaml:"user_avatar_sync"`
 
 	SyncDirectChatList    bool `yaml:"sync_direct_chat_list"`
@@ -58,16 +68,59 @@ type BridgeConfig struct {
 
 	DisableBridgeAlerts bool `yaml:"disable_bridge_alerts"`
 
+	Audio struct {
+		Backend string `yaml:"backend"`
+		Workers int    `yaml:"workers"`
+	} `yaml:"audio"`
+
+	Media struct {
+		MaxSize       int64 `yaml:"max_size"`
+		WechatMaxSize int64 `yaml:"wechat_max_size"`
+
+		FileDrop struct {
+			UploadURL     string `yaml:"upload_url"`
+			PublicURL     string `yaml:"public_url"`
+			Authorization string `yaml:"authorization"`
+		} `yaml:"file_drop"`
+	} `yaml:"media"`
+
+	Location struct {
+		MapProvider        string `yaml:"map_provider"`
+		ConvertCoordinates bool   `yaml:"convert_coordinates"`
+	} `yaml:"location"`
+
+	InitialChatSync struct {
+		Count    int    `yaml:"count"`
+		DelayStr string `yaml:"delay"`
+		Backfill int    `yaml:"backfill"`
+
+		Delay time.Duration `yaml:"-"`
+	} `yaml:"initial_chat_sync"`
+
+	PortalArchive struct {
+		InactiveDays     int    `yaml:"inactive_days"`
+		Action           string `yaml:"action"`
+		Tag              string `yaml:"tag"`
+		PrivateChats     bool   `yaml:"private_chats"`
+		CheckIntervalStr string `yaml:"check_interval"`
+
+		CheckInterval time.Duration `yaml:"-"`
+	} `yaml:"portal_archive"`
+
 	CommandPrefix string `yaml:"command_prefix"`
 
 	ManagementRoomText bridgeconfig.ManagementRoomTexts `yaml:"management_room_text"`
 
 	Encryption bridgeconfig.EncryptionConfig `yaml:"encryption"`
 
+	Relay RelaybotConfig `yaml:"relay"`
+
 	Permissions bridgeconfig.PermissionConfig `yaml:"permissions"`
 
-	parsedUsernameTemplate *template.Template `yaml:"-"`
-	displaynameTemplate    *template.Template `yaml:"-"`
+	parsedUsernameTemplate    *template.Template `yaml:"-"`
+	displaynameTemplate       *template.Template `yaml:"-"`
+	roomDisplaynameTemplate   *template.Template `yaml:"-"`
+	remarkDisplaynameTemplate *template.Template `yaml:"-"`
 }
 
 type umBridgeConfig BridgeConfig
@@ -138,6 +191,44 @@ func (bc *BridgeConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		return err
 	}
 
+	if len(bc.RoomDisplaynameTemplate) > 0 {
+		bc.roomDisplaynameTemplate, err = template.New("room_displayname").Parse(bc.RoomDisplaynameTemplate)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(bc.RemarkDisplaynameTemplate) > 0 {
+		bc.remarkDisplaynameTemplate, err = template.New("remark_displayname").Parse(bc.RemarkDisplaynameTemplate)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch bc.Audio.Backend {
+	case "", "auto", "ffmpeg":
+	default:
+		return fmt.Errorf("bridge.audio.backend: unknown audio backend %q", bc.Audio.Backend)
+	}
+
+	if bc.InitialChatSync.DelayStr != "" {
+		bc.InitialChatSync.Delay, err = time.ParseDuration(bc.InitialChatSync.DelayStr)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(bc.PortalArchive.Tag) == 0 {
+		bc.PortalArchive.Tag = "m.lowpriority"
+	}
+
+	if bc.PortalArchive.CheckIntervalStr != "" {
+		bc.PortalArchive.CheckInterval, err = time.ParseDuration(bc.PortalArchive.CheckIntervalStr)
+		if err != nil {
+			return err
+		}
+	}
+
 	if bc.MessageHandlingTimeout.ErrorAfterStr != "" {
 		bc.MessageHandlingTimeout.ErrorAfter, err = time.ParseDuration(bc.MessageHandlingTimeout.ErrorAfterStr)
 		if err != nil {
@@ -169,6 +260,46 @@ func (bc BridgeConfig) FormatDisplayname(contact types.ContactInfo) (string, int
 	return buf.String(), quality
 }
 
+type RoomDisplaynameParams struct {
+	types.ContactInfo
+	GroupNickname string
+}
+
+// FormatRoomDisplayname returns the displayname of a group member in a single
+// room, or an empty string if the member has no group nickname.
+func (bc BridgeConfig) FormatRoomDisplayname(contact types.ContactInfo, groupNickname string) string {
+	if len(groupNickname) == 0 {
+		return ""
+	}
+
+	if bc.roomDisplaynameTemplate == nil {
+		name, _ := bc.FormatDisplayname(*types.NewContact(contact.Uin, groupNickname, groupNickname))
+		return name
+	}
+
+	var buf strings.Builder
+	_ = bc.roomDisplaynameTemplate.Execute(&buf, RoomDisplaynameParams{
+		ContactInfo:   contact,
+		GroupNickname: groupNickname,
+	})
+
+	return buf.String()
+}
+
+// FormatRemarkDisplayname returns the displayname of a contact based on the
+// private remark of a single bridge user, or an empty string if there's no
+// remark or remark based names are disabled.
+func (bc BridgeConfig) FormatRemarkDisplayname(contact types.ContactInfo) string {
+	if len(contact.Remark) == 0 || bc.remarkDisplaynameTemplate == nil {
+		return ""
+	}
+
+	var buf strings.Builder
+	_ = bc.remarkDisplaynameTemplate.Execute(&buf, contact)
+
+	return buf.String()
+}
+
 func (bc BridgeConfig) FormatUsername(username string) string {
 	var buf strings.Builder
 	_ = bc.parsedUsernameTemplate.Execute(&buf, username)
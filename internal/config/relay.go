@@ -0,0 +1,72 @@
+package config
+
+import (
+	"strings"
+	"text/template"
+
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+)
+
+type RelaybotConfig struct {
+	Enabled        bool                         `yaml:"enabled"`
+	AdminOnly      bool                         `yaml:"admin_only"`
+	MessageFormats map[event.MessageType]string `yaml:"message_formats"`
+
+	messageTemplates *template.Template `yaml:"-"`
+}
+
+type umRelaybotConfig RelaybotConfig
+
+func (rc *RelaybotConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	err := unmarshal((*umRelaybotConfig)(rc))
+	if err != nil {
+		return err
+	}
+
+	rc.messageTemplates = template.New("messageTemplates")
+	for key, format := range rc.MessageFormats {
+		_, err = rc.messageTemplates.New(string(key)).Parse(format)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type Sender struct {
+	UserID string
+	event.MemberEventContent
+}
+
+type formatData struct {
+	Sender  Sender
+	Message string
+	Content *event.MessageEventContent
+}
+
+// HasFormat reports whether there is a relay template for the message type.
+func (rc *RelaybotConfig) HasFormat(msgType event.MessageType) bool {
+	return rc.messageTemplates != nil && rc.messageTemplates.Lookup(string(msgType)) != nil
+}
+
+// FormatMessage renders the relay template of the content's message type.
+// The message argument is the already converted plain text body.
+func (rc *RelaybotConfig) FormatMessage(content *event.MessageEventContent, message string, sender id.UserID, member event.MemberEventContent) (string, error) {
+	if len(member.Displayname) == 0 {
+		member.Displayname = sender.String()
+	}
+
+	var output strings.Builder
+	err := rc.messageTemplates.ExecuteTemplate(&output, string(content.MsgType), formatData{
+		Sender: Sender{
+			UserID:             sender.String(),
+			MemberEventContent: member,
+		},
+		Message: message,
+		Content: content,
+	})
+
+	return output.String(), err
+}
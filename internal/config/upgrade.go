@@ -14,11 +14,16 @@ func DoUpgrade(helper *up.Helper) {
 	helper.Copy(up.Str, "bridge", "displayname_template")
 	helper.Copy(up.Str, "bridge", "listen_address")
 	helper.Copy(up.Str, "bridge", "listen_secret")
+	helper.Copy(up.Str|up.Null, "bridge", "room_displayname_template")
+	helper.Copy(up.Str|up.Null, "bridge", "remark_displayname_template")
 	helper.Copy(up.Bool, "bridge", "personal_filtering_spaces")
 	helper.Copy(up.Bool, "bridge", "message_status_events")
 	helper.Copy(up.Bool, "bridge", "message_error_notices")
 	helper.Copy(up.Int, "bridge", "portal_message_buffer")
 	helper.Copy(up.Bool, "bridge", "allow_redaction")
+	helper.Copy(up.Str, "bridge", "outbound_formatting")
+	helper.Copy(up.Str, "bridge", "emoticons", "outbound")
+	helper.Copy(up.Map, "bridge", "emoticons", "overrides")
 	helper.Copy(up.Bool, "bridge", "user_avatar_sync")
 	helper.Copy(up.Bool, "bridge", "sync_direct_chat_list")
 	helper.Copy(up.Bool, "bridge", "default_bridge_presence")
@@ -50,6 +55,23 @@ func DoUpgrade(helper *up.Helper) {
 	helper.Copy(up.Bool, "bridge", "disable_bridge_alerts")
 	helper.Copy(up.Str|up.Null, "bridge", "message_handling_timeout", "error_after")
 	helper.Copy(up.Str|up.Null, "bridge", "message_handling_timeout", "deadline")
+	helper.Copy(up.Str, "bridge", "audio", "backend")
+	helper.Copy(up.Int, "bridge", "audio", "workers")
+	helper.Copy(up.Int, "bridge", "media", "max_size")
+	helper.Copy(up.Int, "bridge", "media", "wechat_max_size")
+	helper.Copy(up.Str, "bridge", "media", "file_drop", "upload_url")
+	helper.Copy(up.Str, "bridge", "media", "file_drop", "public_url")
+	helper.Copy(up.Str, "bridge", "media", "file_drop", "authorization")
+	helper.Copy(up.Str, "bridge", "location", "map_provider")
+	helper.Copy(up.Bool, "bridge", "location", "convert_coordinates")
+	helper.Copy(up.Int, "bridge", "initial_chat_sync", "count")
+	helper.Copy(up.Str, "bridge", "initial_chat_sync", "delay")
+	helper.Copy(up.Int, "bridge", "initial_chat_sync", "backfill")
+	helper.Copy(up.Int, "bridge", "portal_archive", "inactive_days")
+	helper.Copy(up.Str, "bridge", "portal_archive", "action")
+	helper.Copy(up.Str, "bridge", "portal_archive", "tag")
+	helper.Copy(up.Bool, "bridge", "portal_archive", "private_chats")
+	helper.Copy(up.Str|up.Null, "bridge", "portal_archive", "check_interval")
 
 	helper.Copy(up.Str, "bridge", "management_room_text", "welcome")
 	helper.Copy(up.Str, "bridge", "management_room_text", "welcome_connected")
@@ -89,6 +111,9 @@ func DoUpgrade(helper *up.Helper) {
 	helper.Copy(up.Int, "bridge", "encryption", "rotation", "messages")
 	helper.Copy(up.Bool, "bridge", "encryption", "rotation", "disable_device_change_key_rotation")
 	helper.Copy(up.Map, "bridge", "permissions")
+	helper.Copy(up.Bool, "bridge", "relay", "enabled")
+	helper.Copy(up.Bool, "bridge", "relay", "admin_only")
+	helper.Copy(up.Map, "bridge", "relay", "message_formats")
 }
 
 var SpacedBlocks = [][]string{
@@ -103,5 +128,6 @@ var SpacedBlocks = [][]string{
 	{"bridge", "management_room_text"},
 	{"bridge", "encryption"},
 	{"bridge", "permissions"},
+	{"bridge", "relay"},
 	{"logging"},
 }
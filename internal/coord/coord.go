@@ -0,0 +1,86 @@
+// Package coord converts between the WGS-84 datum used by Matrix clients and
+// GPS, and the GCJ-02 datum mandated for maps of mainland China and used by
+// WeChat.
+package coord
+
+import "math"
+
+const (
+	// Krasovsky 1940 ellipsoid used by GCJ-02.
+	semiMajorAxis = 6378245.0
+	eccentricity2 = 0.00669342162296594323
+
+	// GCJToWGS refines its estimate until it's closer than this (in degrees).
+	precision     = 1e-9
+	maxIterations = 30
+)
+
+// OutOfChina reports whether the point is outside the area where GCJ-02 applies.
+// Outside of it both datums are identical.
+func OutOfChina(lat, lng float64) bool {
+	return lng < 72.004 || lng > 137.8347 || lat < 0.8293 || lat > 55.8271
+}
+
+// WGSToGCJ converts WGS-84 coordinates into GCJ-02.
+func WGSToGCJ(lat, lng float64) (float64, float64) {
+	if OutOfChina(lat, lng) {
+		return lat, lng
+	}
+	dLat, dLng := delta(lat, lng)
+
+	return lat + dLat, lng + dLng
+}
+
+// GCJToWGS converts GCJ-02 coordinates into WGS-84. GCJ-02 has no closed form
+// inverse, so the offset is refined iteratively.
+func GCJToWGS(lat, lng float64) (float64, float64) {
+	if OutOfChina(lat, lng) {
+		return lat, lng
+	}
+
+	wgsLat, wgsLng := lat, lng
+	for i := 0; i < maxIterations; i++ {
+		gcjLat, gcjLng := WGSToGCJ(wgsLat, wgsLng)
+		errLat, errLng := gcjLat-lat, gcjLng-lng
+		if math.Abs(errLat) < precision && math.Abs(errLng) < precision {
+			break
+		}
+		wgsLat -= errLat
+		wgsLng -= errLng
+	}
+
+	return wgsLat, wgsLng
+}
+
+func delta(lat, lng float64) (float64, float64) {
+	dLat := transformLat(lng-105.0, lat-35.0)
+	dLng := transformLng(lng-105.0, lat-35.0)
+
+	radLat := lat / 180.0 * math.Pi
+	magic := math.Sin(radLat)
+	magic = 1 - eccentricity2*magic*magic
+	sqrtMagic := math.Sqrt(magic)
+
+	dLat = (dLat * 180.0) / ((semiMajorAxis * (1 - eccentricity2)) / (magic * sqrtMagic) * math.Pi)
+	dLng = (dLng * 180.0) / (semiMajorAxis / sqrtMagic * math.Cos(radLat) * math.Pi)
+
+	return dLat, dLng
+}
+
+func transformLat(x, y float64) float64 {
+	ret := -100.0 + 2.0*x + 3.0*y + 0.2*y*y + 0.1*x*y + 0.2*math.Sqrt(math.Abs(x))
+	ret += (20.0*math.Sin(6.0*x*math.Pi) + 20.0*math.Sin(2.0*x*math.Pi)) * 2.0 / 3.0
+	ret += (20.0*math.Sin(y*math.Pi) + 40.0*math.Sin(y/3.0*math.Pi)) * 2.0 / 3.0
+	ret += (160.0*math.Sin(y/12.0*math.Pi) + 320*math.Sin(y*math.Pi/30.0)) * 2.0 / 3.0
+
+	return ret
+}
+
+func transformLng(x, y float64) float64 {
+	ret := 300.0 + x + 2.0*y + 0.1*x*x + 0.1*x*y + 0.1*math.Sqrt(math.Abs(x))
+	ret += (20.0*math.Sin(6.0*x*math.Pi) + 20.0*math.Sin(2.0*x*math.Pi)) * 2.0 / 3.0
+	ret += (20.0*math.Sin(x*math.Pi) + 40.0*math.Sin(x/3.0*math.Pi)) * 2.0 / 3.0
+	ret += (150.0*math.Sin(x/12.0*math.Pi) + 300.0*math.Sin(x/30.0*math.Pi)) * 2.0 / 3.0
+
+	return ret
+}
@@ -0,0 +1,70 @@
+package coord
+
+import (
+	"math"
+	"testing"
+)
+
+// Tiananmen Square, the commonly used reference point of GCJ-02.
+const (
+	wgsLat, wgsLng = 39.908722, 116.397499
+	gcjLat, gcjLng = 39.910126, 116.403743
+)
+
+// distance returns the distance between two points in meters.
+func distance(lat1, lng1, lat2, lng2 float64) float64 {
+	const earthRadius = 6371000.0
+	rad := math.Pi / 180.0
+
+	dLat := (lat2 - lat1) * rad
+	dLng := (lng2 - lng1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+func TestWGSToGCJ(t *testing.T) {
+	lat, lng := WGSToGCJ(wgsLat, wgsLng)
+	if math.Abs(lat-gcjLat) > 1e-5 || math.Abs(lng-gcjLng) > 1e-5 {
+		t.Errorf("WGSToGCJ(%f, %f) = (%f, %f), want (%f, %f)", wgsLat, wgsLng, lat, lng, gcjLat, gcjLng)
+	}
+}
+
+func TestGCJToWGS(t *testing.T) {
+	lat, lng := GCJToWGS(gcjLat, gcjLng)
+	if math.Abs(lat-wgsLat) > 1e-5 || math.Abs(lng-wgsLng) > 1e-5 {
+		t.Errorf("GCJToWGS(%f, %f) = (%f, %f), want (%f, %f)", gcjLat, gcjLng, lat, lng, wgsLat, wgsLng)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	points := [][2]float64{
+		{wgsLat, wgsLng},
+		{31.230416, 121.473701}, // Shanghai
+		{22.543096, 114.057865}, // Shenzhen
+		{43.825592, 87.616848},  // Ürümqi
+	}
+
+	for _, point := range points {
+		lat, lng := GCJToWGS(WGSToGCJ(point[0], point[1]))
+		if d := distance(point[0], point[1], lat, lng); d > 1 {
+			t.Errorf("round trip of (%f, %f) is off by %.3f m", point[0], point[1], d)
+		}
+	}
+}
+
+func TestOutOfChina(t *testing.T) {
+	// Paris
+	lat, lng := 48.856613, 2.352222
+	if !OutOfChina(lat, lng) {
+		t.Fatalf("(%f, %f) should be out of China", lat, lng)
+	}
+
+	if gotLat, gotLng := WGSToGCJ(lat, lng); gotLat != lat || gotLng != lng {
+		t.Errorf("WGSToGCJ changed (%f, %f) to (%f, %f)", lat, lng, gotLat, gotLng)
+	}
+	if gotLat, gotLng := GCJToWGS(lat, lng); gotLat != lat || gotLng != lng {
+		t.Errorf("GCJToWGS changed (%f, %f) to (%f, %f)", lat, lng, gotLat, gotLng)
+	}
+}
@@ -19,6 +19,7 @@ type Database struct {
 	Portal  *PortalQuery
 	Puppet  *PuppetQuery
 	Message *MessageQuery
+	Media   *MediaQuery
 }
 
 func New(baseDB *dbutil.Database, log zerolog.Logger) *Database {
@@ -40,6 +41,10 @@ func New(baseDB *dbutil.Database, log zerolog.Logger) *Database {
 		db:  db,
 		log: log.With().Str("query", "Message").Logger(),
 	}
+	db.Media = &MediaQuery{
+		db:  db,
+		log: log.With().Str("query", "Media").Logger(),
+	}
 	return db
 }
 
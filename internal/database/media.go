@@ -0,0 +1,85 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+
+	"github.com/rs/zerolog"
+	"go.mau.fi/util/dbutil"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+)
+
+// Media is a file which was already uploaded to the homeserver, identified by
+// the SHA-256 hash of its plaintext. Uploads to encrypted rooms are cached
+// separately together with their encryption info.
+type Media struct {
+	db  *Database
+	log zerolog.Logger
+
+	Hash      string
+	Encrypted bool
+	MXC       id.ContentURI
+	File      *event.EncryptedFileInfo
+}
+
+// MediaHash returns the key under which the given plaintext is cached.
+func MediaHash(data []byte) string {
+	hash := sha256.Sum256(data)
+
+	return hex.EncodeToString(hash[:])
+}
+
+func (m *Media) Scan(row dbutil.Scannable) *Media {
+	var mxc string
+	var encFile sql.NullString
+	err := row.Scan(&m.Hash, &m.Encrypted, &mxc, &encFile)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			m.log.Error().Msgf("Database scan failed: %v", err)
+		}
+
+		return nil
+	}
+	if m.MXC, err = id.ParseContentURI(mxc); err != nil {
+		m.log.Warn().Msgf("Invalid mxc URI for media %s: %v", m.Hash, err)
+		return nil
+	}
+	if encFile.Valid && len(encFile.String) > 0 {
+		m.File = &event.EncryptedFileInfo{}
+		if err := json.Unmarshal([]byte(encFile.String), m.File); err != nil {
+			m.log.Warn().Msgf("Invalid encryption info for media %s: %v", m.Hash, err)
+			return nil
+		}
+	}
+
+	return m
+}
+
+func (m *Media) Insert() {
+	var encFile *string
+	if m.File != nil {
+		data, err := json.Marshal(m.File)
+		if err != nil {
+			m.log.Warn().Msgf("Failed to marshal encryption info of media %s: %v", m.Hash, err)
+			return
+		}
+		encFile = strPtr(string(data))
+	}
+
+	query := `
+		INSERT INTO media (hash, encrypted, mxc, enc_file)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (hash, encrypted) DO NOTHING
+	`
+	args := []interface{}{
+		m.Hash, m.Encrypted, m.MXC.String(), encFile,
+	}
+	_, err := m.db.Exec(query, args...)
+	if err != nil {
+		m.log.Warn().Msgf("Failed to insert media %s: %v", m.Hash, err)
+	}
+}
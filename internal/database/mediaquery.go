@@ -0,0 +1,32 @@
+package database
+
+import (
+	"github.com/rs/zerolog"
+)
+
+type MediaQuery struct {
+	db  *Database
+	log zerolog.Logger
+}
+
+func (mq *MediaQuery) New() *Media {
+	return &Media{
+		db:  mq.db,
+		log: mq.log,
+	}
+}
+
+const getMediaQuery = `
+	SELECT hash, encrypted, mxc, enc_file
+	FROM media
+	WHERE hash=$1 AND encrypted=$2
+`
+
+func (mq *MediaQuery) Get(hash string, encrypted bool) *Media {
+	row := mq.db.QueryRow(getMediaQuery, hash, encrypted)
+	if row == nil {
+		return nil
+	}
+
+	return mq.New().Scan(row)
+}
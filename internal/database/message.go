@@ -3,6 +3,7 @@ package database
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
@@ -43,6 +44,16 @@ type Message struct {
 	Error     MessageErrorType
 }
 
+// PartMsgID returns the ID under which the nth Matrix event of a WeChat
+// message is stored. The first part keeps the original message ID.
+func PartMsgID(msgID string, index int) string {
+	if index == 0 {
+		return msgID
+	}
+
+	return fmt.Sprintf("%s:%d", msgID, index)
+}
+
 func (m *Message) IsFakeMXID() bool {
 	return strings.HasPrefix(m.MXID.String(), "me.lxduo.wechat.fake::")
 }
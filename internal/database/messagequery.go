@@ -28,6 +28,11 @@ const (
 		FROM message
 		WHERE chat_uid=$1 AND chat_receiver=$2 AND msg_id=$3
 	`
+	getMessagePartsQuery = `
+		SELECT chat_uid, chat_receiver, msg_id, mxid, sender, timestamp, sent, type, error
+		FROM message
+		WHERE chat_uid=$1 AND chat_receiver=$2 AND (msg_id=$3 OR msg_id LIKE $4)
+	`
 	getMessageByMXIDQuery = `
 		SELECT chat_uid, chat_receiver, msg_id, mxid, sender, timestamp, sent, type, error
 		FROM message
@@ -49,6 +54,14 @@ func (mq *MessageQuery) GetAll(chat PortalKey) []*Message {
 	return messages
 }
 
+// DeleteAll deletes all messages of a chat.
+func (mq *MessageQuery) DeleteAll(chat PortalKey) {
+	_, err := mq.db.Exec("DELETE FROM message WHERE chat_uid=$1 AND chat_receiver=$2", chat.UID, chat.Receiver)
+	if err != nil {
+		mq.log.Warn().Msgf("Failed to delete messages of %s: %v", chat, err)
+	}
+}
+
 func (mq *MessageQuery) GetByMsgID(chat PortalKey, msgID string) *Message {
 	row := mq.db.QueryRow(getMessageByMsgIDQuery, chat.UID, chat.Receiver, msgID)
 	if row == nil {
@@ -58,6 +71,24 @@ func (mq *MessageQuery) GetByMsgID(chat PortalKey, msgID string) *Message {
 	return mq.New().Scan(row)
 }
 
+// GetAllParts returns every Matrix event bridged from a single WeChat message.
+func (mq *MessageQuery) GetAllParts(chat PortalKey, msgID string) []*Message {
+	messages := []*Message{}
+
+	rows, err := mq.db.Query(getMessagePartsQuery, chat.UID, chat.Receiver, msgID, msgID+":%")
+	if err != nil || rows == nil {
+		return messages
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if msg := mq.New().Scan(rows); msg != nil {
+			messages = append(messages, msg)
+		}
+	}
+
+	return messages
+}
+
 func (mq *MessageQuery) GetByMXID(mxid id.EventID) *Message {
 	row := mq.db.QueryRow(getMessageByMXIDQuery, mxid)
 	if row == nil {
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+)
+
+// Kinds of pending requests, which need an answer of the user.
+const (
+	RequestFriend      = "friend"
+	RequestGroupInvite = "group_invite"
+)
+
+// SavePendingRequest stores a request received from WeChat, so it can still
+// be answered after the bridge is restarted.
+func (u *User) SavePendingRequest(kind, requestID string, data interface{}) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		u.log.Warn().Msgf("Failed to marshal pending %s request %s: %v", kind, requestID, err)
+		return
+	}
+
+	query := `
+		INSERT INTO pending_request (user_mxid, kind, request_id, data)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_mxid, kind, request_id)
+		DO UPDATE SET
+			data=excluded.data
+	`
+	args := []interface{}{
+		u.MXID, kind, requestID, string(raw),
+	}
+
+	if _, err = u.db.Exec(query, args...); err != nil {
+		u.log.Warn().Msgf("Failed to save pending %s request %s: %v", kind, requestID, err)
+	}
+}
+
+// PopPendingRequest loads a pending request into data and deletes it. It
+// reports whether the request was found.
+func (u *User) PopPendingRequest(kind, requestID string, data interface{}) bool {
+	query := `
+		DELETE FROM pending_request
+		WHERE user_mxid=$1 AND kind=$2 AND request_id=$3
+		RETURNING data
+	`
+	args := []interface{}{
+		u.MXID, kind, requestID,
+	}
+
+	var raw string
+	err := u.db.QueryRow(query, args...).Scan(&raw)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			u.log.Warn().Msgf("Failed to delete pending %s request %s: %v", kind, requestID, err)
+		}
+		return false
+	}
+	if err = json.Unmarshal([]byte(raw), data); err != nil {
+		u.log.Warn().Msgf("Invalid data of pending %s request %s: %v", kind, requestID, err)
+		return false
+	}
+
+	return true
+}
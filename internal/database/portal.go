@@ -28,15 +28,20 @@ type Portal struct {
 
 	FirstEventID id.EventID
 	NextBatchID  id.BatchID
+
+	RelayUserID id.UserID
+	Formatting  string
+	// Unbridged group portals aren't recreated by new messages.
+	Unbridged bool
 }
 
 func (p *Portal) Scan(row dbutil.Scannable) *Portal {
-	var mxid, avatarURL, firstEventID, nextBatchID sql.NullString
+	var mxid, avatarURL, firstEventID, nextBatchID, relayUserID, formatting sql.NullString
 	var lastSyncTs int64
 	err := row.Scan(
 		&p.Key.UID, &p.Key.Receiver, &mxid, &p.Name, &p.NameSet,
 		&p.Topic, &p.TopicSet, &p.Avatar, &avatarURL, &p.AvatarSet,
-		&p.Encrypted, &lastSyncTs, &firstEventID, &nextBatchID,
+		&p.Encrypted, &lastSyncTs, &firstEventID, &nextBatchID, &relayUserID, &formatting, &p.Unbridged,
 	)
 	if err != nil {
 		if err != sql.ErrNoRows {
@@ -52,6 +57,8 @@ func (p *Portal) Scan(row dbutil.Scannable) *Portal {
 	p.AvatarURL, _ = id.ParseContentURI(avatarURL.String)
 	p.FirstEventID = id.EventID(firstEventID.String)
 	p.NextBatchID = id.BatchID(nextBatchID.String)
+	p.RelayUserID = id.UserID(relayUserID.String)
+	p.Formatting = formatting.String
 
 	return p
 }
@@ -59,13 +66,14 @@ func (p *Portal) Scan(row dbutil.Scannable) *Portal {
 func (p *Portal) Insert() {
 	query := `
 		INSERT INTO portal (uid, receiver, mxid, name, name_set, topic, topic_set, avatar, avatar_url,
-							avatar_set, encrypted, last_sync, first_event_id, next_batch_id)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+							avatar_set, encrypted, last_sync, first_event_id, next_batch_id, relay_user_id, formatting, unbridged)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
 	`
 	args := []interface{}{
 		p.Key.UID, p.Key.Receiver, strPtr(p.MXID.String()), p.Name, p.NameSet, p.Topic,
 		p.TopicSet, p.Avatar, p.AvatarURL.String(), p.AvatarSet, p.Encrypted,
-		p.lastSyncTs(), p.FirstEventID.String(), p.NextBatchID.String(),
+		p.lastSyncTs(), p.FirstEventID.String(), p.NextBatchID.String(), strPtr(p.RelayUserID.String()),
+		strPtr(p.Formatting), p.Unbridged,
 	}
 
 	_, err := p.db.Exec(query, args...)
@@ -78,12 +86,14 @@ func (p *Portal) Update(txn dbutil.Transaction) {
 	query := `
 		UPDATE portal
 		SET mxid=$1, name=$2, name_set=$3, topic=$4, topic_set=$5, avatar=$6, avatar_url=$7,
-			avatar_set=$8, encrypted=$9, last_sync=$10, first_event_id=$11, next_batch_id=$12
-		WHERE uid=$13 AND receiver=$14`
+			avatar_set=$8, encrypted=$9, last_sync=$10, first_event_id=$11, next_batch_id=$12,
+			relay_user_id=$13, formatting=$14, unbridged=$15
+		WHERE uid=$16 AND receiver=$17`
 	args := []interface{}{
 		strPtr(p.MXID.String()), p.Name, p.NameSet, p.Topic, p.TopicSet, p.Avatar,
 		p.AvatarURL.String(), p.AvatarSet, p.Encrypted, p.lastSyncTs(), p.FirstEventID.String(),
-		p.NextBatchID.String(), p.Key.UID, p.Key.Receiver,
+		p.NextBatchID.String(), strPtr(p.RelayUserID.String()), strPtr(p.Formatting), p.Unbridged,
+		p.Key.UID, p.Key.Receiver,
 	}
 
 	var err error
@@ -1,7 +1,9 @@
 package database
 
 import (
+	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/duo/matrix-wechat/internal/types"
 
@@ -11,7 +13,7 @@ import (
 
 const portalColumns = `
 	uid, receiver, mxid, name, name_set, topic, topic_set, avatar, avatar_url,
-	avatar_set, encrypted, last_sync, first_event_id, next_batch_id
+	avatar_set, encrypted, last_sync, first_event_id, next_batch_id, relay_user_id, formatting, unbridged
 `
 
 type PortalQuery struct {
@@ -65,6 +67,16 @@ func (pq *PortalQuery) FindPrivateChats(receiver types.UID) []*Portal {
 	return pq.getAll(query, args...)
 }
 
+func (pq *PortalQuery) FindGroupChats(receiver types.UID) []*Portal {
+	query := fmt.Sprintf(
+		"SELECT %s FROM portal WHERE receiver=$1 AND mxid<>'' AND uid LIKE '%%%s%s'",
+		portalColumns, types.SEP_UID, types.Group,
+	)
+	args := []interface{}{receiver}
+
+	return pq.getAll(query, args...)
+}
+
 func (pq *PortalQuery) FindPrivateChatsNotInSpace(receiver types.UID) []PortalKey {
 	keys := []PortalKey{}
 
@@ -93,6 +105,46 @@ func (pq *PortalQuery) FindPrivateChatsNotInSpace(receiver types.UID) []PortalKe
 	return keys
 }
 
+// PortalActivity is the time of the last bridged message in a portal. Portals
+// without any bridged message have a zero LastMessage.
+type PortalActivity struct {
+	Key         PortalKey
+	LastMessage time.Time
+}
+
+func (pq *PortalQuery) GetActivity(receiver types.UID) []PortalActivity {
+	activity := []PortalActivity{}
+
+	query := `
+		SELECT portal.uid, MAX(message.timestamp) FROM portal
+			LEFT JOIN message ON portal.uid=message.chat_uid AND portal.receiver=message.chat_receiver
+		WHERE portal.mxid<>'' AND portal.receiver=$1
+		GROUP BY portal.uid
+	`
+	args := []interface{}{receiver}
+
+	rows, err := pq.db.Query(query, args...)
+	if err != nil || rows == nil {
+		return activity
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		item := PortalActivity{Key: PortalKey{Receiver: receiver}}
+		var ts sql.NullInt64
+		if err = rows.Scan(&item.Key.UID, &ts); err != nil {
+			pq.log.Warn().Msgf("Failed to scan portal activity: %v", err)
+			continue
+		}
+		if ts.Valid && ts.Int64 > 0 {
+			item.LastMessage = time.Unix(ts.Int64, 0)
+		}
+		activity = append(activity, item)
+	}
+
+	return activity
+}
+
 func (pq *PortalQuery) getAll(query string, args ...interface{}) []*Portal {
 	portals := []*Portal{}
 
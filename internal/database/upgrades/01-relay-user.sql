@@ -0,0 +1,2 @@
+-- v1 -> v2: Add relay user to portals
+ALTER TABLE portal ADD COLUMN relay_user_id TEXT;
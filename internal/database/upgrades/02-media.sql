@@ -0,0 +1,8 @@
+-- v2 -> v3: Add media table for deduplicating uploads
+CREATE TABLE media (
+    hash      TEXT,
+    encrypted BOOLEAN NOT NULL DEFAULT false,
+    mxc       TEXT NOT NULL,
+    enc_file  TEXT,
+    PRIMARY KEY (hash, encrypted)
+);
@@ -0,0 +1,2 @@
+-- v3 -> v4: Add outbound formatting mode to portals
+ALTER TABLE portal ADD COLUMN formatting TEXT;
@@ -0,0 +1,8 @@
+-- v4 -> v5: Add per-user contact remarks
+CREATE TABLE user_contact (
+    user_mxid   TEXT,
+    contact_uin TEXT,
+    remark      TEXT NOT NULL,
+    PRIMARY KEY (user_mxid, contact_uin),
+    FOREIGN KEY (user_mxid) REFERENCES "user"(mxid) ON UPDATE CASCADE ON DELETE CASCADE
+);
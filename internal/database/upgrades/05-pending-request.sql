@@ -0,0 +1,9 @@
+-- v5 -> v6: Store pending friend requests
+CREATE TABLE pending_request (
+    user_mxid  TEXT,
+    kind       TEXT,
+    request_id TEXT,
+    data       TEXT NOT NULL,
+    PRIMARY KEY (user_mxid, kind, request_id),
+    FOREIGN KEY (user_mxid) REFERENCES "user"(mxid) ON UPDATE CASCADE ON DELETE CASCADE
+);
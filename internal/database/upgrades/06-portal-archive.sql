@@ -0,0 +1,3 @@
+-- v6 -> v7: Add archiving of inactive portals
+ALTER TABLE "user" ADD COLUMN archive_space_room TEXT NOT NULL DEFAULT '';
+ALTER TABLE user_portal ADD COLUMN archived BOOLEAN NOT NULL DEFAULT false;
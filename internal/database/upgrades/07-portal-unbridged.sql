@@ -0,0 +1,2 @@
+-- v7 -> v8: Remember unbridged group portals
+ALTER TABLE portal ADD COLUMN unbridged BOOLEAN NOT NULL DEFAULT false;
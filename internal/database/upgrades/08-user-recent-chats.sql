@@ -0,0 +1,4 @@
+-- v8 -> v9: Remember whether portals for recent chats were created
+ALTER TABLE "user" ADD COLUMN recent_chats_synced BOOLEAN NOT NULL DEFAULT false;
+-- Users who logged in before already had their chance.
+UPDATE "user" SET recent_chats_synced=true WHERE uin IS NOT NULL AND uin<>'';
@@ -20,16 +20,21 @@ type User struct {
 	UID            types.UID
 	ManagementRoom id.RoomID
 	SpaceRoom      id.RoomID
+	ArchiveSpace   id.RoomID
+	// RecentChatsSynced is set once portals for recent chats were created after the first login.
+	RecentChatsSynced bool
 
 	lastReadCache     map[PortalKey]time.Time
 	lastReadCacheLock sync.Mutex
 	inSpaceCache      map[PortalKey]bool
 	inSpaceCacheLock  sync.Mutex
+	remarkCache       map[string]string
+	remarkCacheLock   sync.Mutex
 }
 
 func (u *User) Scan(row dbutil.Scannable) *User {
 	var uin sql.NullString
-	err := row.Scan(&u.MXID, &uin, &u.ManagementRoom, &u.SpaceRoom)
+	err := row.Scan(&u.MXID, &uin, &u.ManagementRoom, &u.SpaceRoom, &u.ArchiveSpace, &u.RecentChatsSynced)
 	if err != nil {
 		if err != sql.ErrNoRows {
 			u.log.Error().Msgf("Database scan failed: %v", err)
@@ -46,11 +51,11 @@ func (u *User) Scan(row dbutil.Scannable) *User {
 
 func (u *User) Insert() {
 	query := `
-		INSERT INTO "user" (mxid, uin, management_room, space_room)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO "user" (mxid, uin, management_room, space_room, archive_space_room, recent_chats_synced)
+		VALUES ($1, $2, $3, $4, $5, $6)
 	`
 	args := []interface{}{
-		u.MXID, u.UID.Uin, u.ManagementRoom, u.SpaceRoom,
+		u.MXID, u.UID.Uin, u.ManagementRoom, u.SpaceRoom, u.ArchiveSpace, u.RecentChatsSynced,
 	}
 
 	_, err := u.db.Exec(query, args...)
@@ -62,11 +67,11 @@ func (u *User) Insert() {
 func (u *User) Update() {
 	query := `
 		UPDATE "user"
-		SET uin=$1, management_room=$2, space_room=$3
-		WHERE mxid=$4
+		SET uin=$1, management_room=$2, space_room=$3, archive_space_room=$4, recent_chats_synced=$5
+		WHERE mxid=$6
 	`
 	args := []interface{}{
-		u.UID.Uin, u.ManagementRoom, u.SpaceRoom, u.MXID,
+		u.UID.Uin, u.ManagementRoom, u.SpaceRoom, u.ArchiveSpace, u.RecentChatsSynced, u.MXID,
 	}
 	_, err := u.db.Exec(query, args...)
 	if err != nil {
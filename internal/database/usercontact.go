@@ -0,0 +1,66 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// GetContactRemark returns the private remark this user has set for a
+// contact, or an empty string if there's none.
+func (u *User) GetContactRemark(uin string) string {
+	u.remarkCacheLock.Lock()
+	defer u.remarkCacheLock.Unlock()
+
+	if cached, ok := u.remarkCache[uin]; ok {
+		return cached
+	}
+
+	query := `
+		SELECT remark
+		FROM user_contact
+		WHERE user_mxid=$1 AND contact_uin=$2
+	`
+	args := []interface{}{
+		u.MXID, uin,
+	}
+
+	var remark string
+	err := u.db.QueryRow(query, args...).Scan(&remark)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		u.log.Warn().Msgf("Failed to scan remark from user contact table: %v", err)
+	}
+	u.remarkCache[uin] = remark
+
+	return remark
+}
+
+// SetContactRemark stores the remark of a contact and reports whether it
+// changed.
+func (u *User) SetContactRemark(uin, remark string) bool {
+	if u.GetContactRemark(uin) == remark {
+		return false
+	}
+
+	u.remarkCacheLock.Lock()
+	defer u.remarkCacheLock.Unlock()
+
+	var err error
+	if len(remark) == 0 {
+		_, err = u.db.Exec("DELETE FROM user_contact WHERE user_mxid=$1 AND contact_uin=$2", u.MXID, uin)
+	} else {
+		_, err = u.db.Exec(`
+			INSERT INTO user_contact (user_mxid, contact_uin, remark)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (user_mxid, contact_uin)
+			DO UPDATE SET
+				remark=excluded.remark
+		`, u.MXID, uin, remark)
+	}
+	if err != nil {
+		u.log.Warn().Msgf("Failed to update remark of %s: %v", uin, err)
+		return false
+	}
+	u.remarkCache[uin] = remark
+
+	return true
+}
@@ -113,3 +113,41 @@ func (u *User) MarkInSpace(portal PortalKey) {
 		u.inSpaceCache[portal] = true
 	}
 }
+
+func (u *User) IsArchived(portal PortalKey) bool {
+	query := `
+		SELECT archived
+		FROM user_portal
+		WHERE user_mxid=$1 AND portal_uid=$2 AND portal_receiver=$3
+	`
+	args := []interface{}{
+		u.MXID, portal.UID, portal.Receiver,
+	}
+
+	var archived bool
+	err := u.db.QueryRow(query, args...).Scan(&archived)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		u.log.Warn().Msgf("Failed to scan archived status from user portal table: %v", err)
+	}
+
+	return archived
+}
+
+func (u *User) SetArchived(portal PortalKey, archived bool) {
+	query := `
+		INSERT INTO user_portal
+			(user_mxid, portal_uid, portal_receiver, archived)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_mxid, portal_uid, portal_receiver)
+		DO UPDATE SET
+			archived=excluded.archived
+	`
+	args := []interface{}{
+		u.MXID, portal.UID, portal.Receiver, archived,
+	}
+
+	_, err := u.db.Exec(query, args...)
+	if err != nil {
+		u.log.Warn().Msgf("Failed to update archived status: %v", err)
+	}
+}
@@ -8,7 +8,7 @@ import (
 	"maunium.net/go/mautrix/id"
 )
 
-const userColumns = "mxid, uin, management_room, space_room"
+const userColumns = "mxid, uin, management_room, space_room, archive_space_room, recent_chats_synced"
 
 type UserQuery struct {
 	db  *Database
@@ -22,6 +22,7 @@ func (uq *UserQuery) New() *User {
 
 		lastReadCache: make(map[PortalKey]time.Time),
 		inSpaceCache:  make(map[PortalKey]bool),
+		remarkCache:   make(map[string]string),
 	}
 }
 
@@ -0,0 +1,135 @@
+// Package emoticon converts between WeChat emoticon codes like [微笑] and
+// Unicode emoji.
+package emoticon
+
+import (
+	_ "embed"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// OutboundOff sends emoji to WeChat as they are.
+	OutboundOff = "off"
+	// OutboundChinese converts emoji into Chinese codes, e.g. [微笑].
+	OutboundChinese = "zh"
+	// OutboundEnglish converts emoji into English codes, e.g. [Smile].
+	OutboundEnglish = "en"
+)
+
+//go:embed emoticons.yaml
+var builtinTable []byte
+
+type entry struct {
+	Emoji string   `yaml:"emoji"`
+	Codes []string `yaml:"codes"`
+}
+
+type Table struct {
+	toEmoji   *strings.Replacer
+	toChinese *strings.Replacer
+	toEnglish *strings.Replacer
+}
+
+// Load builds the conversion table from the built-in emoticons. Overrides map
+// codes to emoji and take precedence over the built-in entries in both
+// directions.
+func Load(overrides map[string]string) (*Table, error) {
+	var entries []entry
+	if err := yaml.Unmarshal(builtinTable, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse emoticon table: %w", err)
+	}
+
+	codeToEmoji := make(map[string]string)
+	emojiToChinese := make(map[string]string)
+	emojiToEnglish := make(map[string]string)
+	add := func(code, emoji string, override bool) {
+		codeToEmoji[code] = emoji
+		target := emojiToEnglish
+		if !isASCII(code) {
+			target = emojiToChinese
+		}
+		if _, ok := target[emoji]; !ok || override {
+			target[emoji] = code
+		}
+	}
+	for _, e := range entries {
+		for _, code := range e.Codes {
+			add(code, e.Emoji, false)
+		}
+	}
+	for code, emoji := range overrides {
+		add(code, emoji, true)
+	}
+
+	// Codes without a variant in the requested language fall back to the other one.
+	for emoji, code := range emojiToChinese {
+		if _, ok := emojiToEnglish[emoji]; !ok {
+			emojiToEnglish[emoji] = code
+		}
+	}
+	for emoji, code := range emojiToEnglish {
+		if _, ok := emojiToChinese[emoji]; !ok {
+			emojiToChinese[emoji] = code
+		}
+	}
+
+	return &Table{
+		toEmoji:   newReplacer(codeToEmoji),
+		toChinese: newReplacer(emojiToChinese),
+		toEnglish: newReplacer(emojiToEnglish),
+	}, nil
+}
+
+// ToEmoji replaces WeChat emoticon codes with emoji.
+func (t *Table) ToEmoji(text string) string {
+	return t.toEmoji.Replace(text)
+}
+
+// ToCodes replaces emoji with WeChat emoticon codes in the given outbound mode.
+func (t *Table) ToCodes(text, mode string) string {
+	switch mode {
+	case OutboundChinese:
+		return t.toChinese.Replace(text)
+	case OutboundEnglish:
+		return t.toEnglish.Replace(text)
+	default:
+		return text
+	}
+}
+
+// newReplacer sorts the replacements longest first, as strings.Replacer
+// prefers earlier arguments and emoji sequences share prefixes.
+func newReplacer(replacements map[string]string) *strings.Replacer {
+	keys := make([]string, 0, len(replacements))
+	for key := range replacements {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+
+	oldnew := make([]string, 0, len(keys)*2)
+	for _, key := range keys {
+		oldnew = append(oldnew, key, replacements[key])
+	}
+
+	return strings.NewReplacer(oldnew...)
+}
+
+func isASCII(text string) bool {
+	for i := 0; i < len(text); i++ {
+		if text[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
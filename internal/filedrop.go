@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// FileDropEnabled reports whether files too large for Matrix can be stored externally.
+func (br *WechatBridge) FileDropEnabled() bool {
+	return len(br.Config.Bridge.Media.FileDrop.UploadURL) > 0
+}
+
+// UploadFileDrop stores a file in the external file drop and returns its public URL.
+func (br *WechatBridge) UploadFileDrop(name string, data []byte) (string, error) {
+	cfg := br.Config.Bridge.Media.FileDrop
+
+	path := randomHex(8) + "/" + url.PathEscape(name)
+	uploadURL := strings.TrimSuffix(cfg.UploadURL, "/") + "/" + path
+	publicURL := cfg.PublicURL
+	if len(publicURL) == 0 {
+		publicURL = cfg.UploadURL
+	}
+
+	req, err := http.NewRequest(http.MethodPut, uploadURL, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if len(cfg.Authorization) > 0 {
+		req.Header.Set("Authorization", cfg.Authorization)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return strings.TrimSuffix(publicURL, "/") + "/" + path, nil
+}
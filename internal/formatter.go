@@ -2,11 +2,15 @@ package internal
 
 import (
 	"fmt"
+	"html"
+	"regexp"
 	"slices"
 	"sort"
+	"strings"
 
 	"github.com/duo/matrix-wechat/internal/types"
 
+	"github.com/rs/zerolog"
 	"maunium.net/go/mautrix/event"
 	"maunium.net/go/mautrix/format"
 	"maunium.net/go/mautrix/id"
@@ -14,44 +18,75 @@ import (
 
 const mentionedUIDsContextKey = "me.lxduo.wechat.mentioned_uids"
 const allowedMentionsContextKey = "me.lxduo.wechat.allowed_mentions"
+const pillsContextKey = "me.lxduo.wechat.pills"
+
+const (
+	// FormattingPlain sends Matrix formatting as plain text, which is what WeChat shows.
+	FormattingPlain = "plain"
+	// FormattingMarkup keeps formatting as *bold*, _italic_ and ```code``` markers.
+	FormattingMarkup = "markup"
+)
+
+var (
+	plainBulletRegex = regexp.MustCompile(`(?m)^(\s*)\* `)
+	wechatURLRegex   = regexp.MustCompile(`https?://[^\s<>"'，。、；：！？）】」]+`)
+)
 
 type Formatter struct {
 	bridge *WechatBridge
 
 	matrixHTMLParser *format.HTMLParser
+	plainHTMLParser  *format.HTMLParser
 }
 
 func NewFormatter(br *WechatBridge) *Formatter {
+	pillConverter := func(displayname, mxid, eventID string, ctx format.Context) string {
+		pills, _ := ctx.ReturnData[pillsContextKey].([]string)
+		ctx.ReturnData[pillsContextKey] = append(pills, mxid)
+
+		allowedMentions, _ := ctx.ReturnData[allowedMentionsContextKey].(map[types.UID]bool)
+		if mxid[0] == '@' {
+			puppet := br.GetPuppetByMXID(id.UserID(mxid))
+			if puppet != nil && (allowedMentions == nil || allowedMentions[puppet.UID]) {
+				if allowedMentions == nil {
+					uids, ok := ctx.ReturnData[mentionedUIDsContextKey].([]string)
+					if !ok {
+						ctx.ReturnData[mentionedUIDsContextKey] = []string{puppet.UID.Uin}
+					} else {
+						ctx.ReturnData[mentionedUIDsContextKey] = append(uids, puppet.UID.Uin)
+					}
+				}
+				return "@" + puppet.UID.Uin
+			}
+		}
+		return displayname
+	}
+	plainText := func(text string, _ format.Context) string { return text }
+
 	formatter := &Formatter{
 		bridge: br,
 		matrixHTMLParser: &format.HTMLParser{
 			TabsToSpaces: 4,
 			Newline:      "\n",
 
-			PillConverter: func(displayname, mxid, eventID string, ctx format.Context) string {
-				allowedMentions, _ := ctx.ReturnData[allowedMentionsContextKey].(map[types.UID]bool)
-				if mxid[0] == '@' {
-					puppet := br.GetPuppetByMXID(id.UserID(mxid))
-					if puppet != nil && (allowedMentions == nil || allowedMentions[puppet.UID]) {
-						if allowedMentions == nil {
-							uids, ok := ctx.ReturnData[mentionedUIDsContextKey].([]string)
-							if !ok {
-								ctx.ReturnData[mentionedUIDsContextKey] = []string{puppet.UID.Uin}
-							} else {
-								ctx.ReturnData[mentionedUIDsContextKey] = append(uids, puppet.UID.Uin)
-							}
-						}
-						return "@" + puppet.UID.Uin
-					}
-				}
-				return displayname
-			},
+			PillConverter:           pillConverter,
 			BoldConverter:           func(text string, _ format.Context) string { return fmt.Sprintf("*%s*", text) },
 			ItalicConverter:         func(text string, _ format.Context) string { return fmt.Sprintf("_%s_", text) },
 			StrikethroughConverter:  func(text string, _ format.Context) string { return fmt.Sprintf("~%s~", text) },
 			MonospaceConverter:      func(text string, _ format.Context) string { return fmt.Sprintf("```%s```", text) },
 			MonospaceBlockConverter: func(text, language string, _ format.Context) string { return fmt.Sprintf("```%s```", text) },
 		},
+		plainHTMLParser: &format.HTMLParser{
+			TabsToSpaces: 4,
+			Newline:      "\n",
+
+			PillConverter:           pillConverter,
+			BoldConverter:           plainText,
+			ItalicConverter:         plainText,
+			StrikethroughConverter:  plainText,
+			MonospaceConverter:      plainText,
+			MonospaceBlockConverter: func(text, language string, _ format.Context) string { return strings.TrimSuffix(text, "\n") },
+		},
 	}
 	return formatter
 }
@@ -74,7 +109,10 @@ func (f *Formatter) GetMatrixInfoByUID(roomID id.RoomID, uid types.UID) (id.User
 	return mxid, displayname
 }
 
-func (f *Formatter) ParseMatrix(html string, mentions *event.Mentions) (string, []string) {
+// ParseMatrix converts Matrix HTML into WeChat text. Pills of WeChat users
+// become "@<uid>" and their UIDs are returned. If the message has m.mentions,
+// only the users listed there are mentioned.
+func (f *Formatter) ParseMatrix(html string, mentions *event.Mentions, mode string, log zerolog.Logger) (string, []string) {
 	ctx := format.NewContext()
 
 	var mentionedUIDs []string
@@ -85,7 +123,6 @@ func (f *Formatter) ParseMatrix(html string, mentions *event.Mentions) (string,
 			var uid types.UID
 			if puppet := f.bridge.GetPuppetByMXID(userID); puppet != nil {
 				uid = puppet.UID
-				mentionedUIDs = append(mentionedUIDs, puppet.UID.Uin)
 			} else if user := f.bridge.GetUserByMXIDIfExists(userID); user != nil {
 				uid = user.UID
 			}
@@ -97,12 +134,130 @@ func (f *Formatter) ParseMatrix(html string, mentions *event.Mentions) (string,
 		ctx.ReturnData[allowedMentionsContextKey] = allowedMentions
 	}
 
-	result := f.matrixHTMLParser.Parse(html, ctx)
+	var result string
+	if mode == FormattingMarkup {
+		result = f.matrixHTMLParser.Parse(html, ctx)
+	} else {
+		result = plainBulletRegex.ReplaceAllString(f.plainHTMLParser.Parse(html, ctx), "${1}• ")
+	}
 	if mentions == nil {
 		mentionedUIDs, _ = ctx.ReturnData[mentionedUIDsContextKey].([]string)
 		sort.Strings(mentionedUIDs)
 		mentionedUIDs = slices.Compact(mentionedUIDs)
 	}
-	f.bridge.ZLog.Error().Msgf("WTF mentions: %+v", mentionedUIDs)
+
+	pills, _ := ctx.ReturnData[pillsContextKey].([]string)
+	source := "pills"
+	if mentions != nil {
+		source = "m.mentions"
+	}
+	log.Debug().
+		Str("mention_source", source).
+		Strs("pills", pills).
+		Strs("mentioned_uids", mentionedUIDs).
+		Msg("Parsed mentions of Matrix message")
+
 	return result, mentionedUIDs
 }
+
+// WechatMention is a user mentioned in a WeChat message, Name is the text
+// which follows the @ sign in the message.
+type WechatMention struct {
+	MXID id.UserID
+	Name string
+	// Displayname is used for the pill, Name if empty.
+	Displayname string
+}
+
+// ParseWechat converts a WeChat text message into Matrix content. URLs are
+// linkified, emoticons are replaced and mentions become pills. Mentions which
+// can't be found in the text are put in front of the message.
+func (f *Formatter) ParseWechat(text string, mentions []WechatMention) *event.MessageEventContent {
+	text = f.bridge.Emoticons.ToEmoji(text)
+	content := &event.MessageEventContent{
+		MsgType: event.MsgText,
+		Body:    text,
+	}
+
+	type span struct {
+		start, end int
+		html       string
+	}
+	var spans []span
+	overlaps := func(start, end int) bool {
+		for _, s := range spans {
+			if start < s.end && end > s.start {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, loc := range wechatURLRegex.FindAllStringIndex(text, -1) {
+		url := strings.TrimRight(text[loc[0]:loc[1]], ".,;:!?)")
+		spans = append(spans, span{loc[0], loc[0] + len(url), fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(url), html.EscapeString(url))})
+	}
+
+	var head strings.Builder
+	var userIDs []id.UserID
+	for _, mention := range mentions {
+		if len(mention.MXID) == 0 {
+			continue
+		}
+		userIDs = append(userIDs, mention.MXID)
+		name := mention.Displayname
+		if len(name) == 0 {
+			name = mention.Name
+		}
+		pill := fmt.Sprintf(`<a href="%s">%s</a>`, mention.MXID.URI().MatrixToURL(), html.EscapeString(name))
+
+		found := false
+		if len(mention.Name) > 0 {
+			needle := "@" + mention.Name
+			for offset := 0; ; {
+				index := strings.Index(text[offset:], needle)
+				if index < 0 {
+					break
+				}
+				start, end := offset+index, offset+index+len(needle)
+				if !overlaps(start, end) {
+					spans = append(spans, span{start, end, pill})
+					found = true
+				}
+				offset = end
+			}
+		}
+		if !found {
+			head.WriteString(pill + " ")
+		}
+	}
+	if len(userIDs) > 0 {
+		content.Mentions = &event.Mentions{UserIDs: userIDs}
+	}
+
+	if len(spans) == 0 && head.Len() == 0 {
+		return content
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	var formatted strings.Builder
+	if head.Len() > 0 {
+		formatted.WriteString(head.String() + "<br>")
+	}
+	last := 0
+	for _, s := range spans {
+		formatted.WriteString(escapeWechatText(text[last:s.start]))
+		formatted.WriteString(s.html)
+		last = s.end
+	}
+	formatted.WriteString(escapeWechatText(text[last:]))
+
+	content.Format = event.FormatHTML
+	content.FormattedBody = formatted.String()
+
+	return content
+}
+
+func escapeWechatText(text string) string {
+	return strings.ReplaceAll(html.EscapeString(text), "\n", "<br>")
+}
@@ -0,0 +1,164 @@
+package internal
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/duo/matrix-wechat/internal/config"
+	"github.com/duo/matrix-wechat/internal/database"
+	"github.com/duo/matrix-wechat/internal/types"
+
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+	"maunium.net/go/mautrix/appservice"
+	"maunium.net/go/mautrix/bridge/bridgeconfig"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+)
+
+const testDomain = "example.com"
+
+// newTestBridge returns a bridge which only knows the given puppets (uid to
+// displayname) and bridge users (mxid to uid), so no database is needed.
+func newTestBridge(t *testing.T, puppets map[string]string, users map[id.UserID]string) *WechatBridge {
+	t.Helper()
+
+	cfg := &config.Config{BaseConfig: &bridgeconfig.BaseConfig{}}
+	err := yaml.Unmarshal([]byte("username_template: wechat_{{.}}\ndisplayname_template: '{{.Name}}'\n"), &cfg.Bridge)
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	cfg.BaseConfig.Bridge = cfg.Bridge
+	cfg.Homeserver.Domain = testDomain
+
+	br := NewWechatBridge("")
+	br.Config = cfg
+	br.Bot = &appservice.IntentAPI{UserID: id.NewUserID("wechatbot", testDomain)}
+	for uin, name := range puppets {
+		uid := types.NewUserUID(uin)
+		br.puppets[uid] = &Puppet{
+			Puppet: &database.Puppet{UID: uid, Displayname: name},
+			bridge: br,
+			MXID:   br.FormatPuppetMXID(uid),
+		}
+	}
+	for mxid, uin := range users {
+		br.usersByMXID[mxid] = &User{User: &database.User{MXID: mxid, UID: types.NewUserUID(uin)}, bridge: br}
+	}
+	br.Formatter = NewFormatter(br)
+
+	return br
+}
+
+func pill(uin, name string) string {
+	return fmt.Sprintf(`<a href="https://matrix.to/#/@wechat_%s:%s">%s</a>`, uin, testDomain, name)
+}
+
+func puppetMXID(uin string) id.UserID {
+	return id.NewUserID("wechat_"+uin, testDomain)
+}
+
+func TestParseMatrix(t *testing.T) {
+	br := newTestBridge(t,
+		map[string]string{"alice": "Alice", "bob": "Bob", "alex1": "Alex", "alex2": "Alex"},
+		map[id.UserID]string{"@carol:example.com": "carol"},
+	)
+
+	tests := []struct {
+		name     string
+		html     string
+		mentions *event.Mentions
+		mode     string
+		text     string
+		uids     []string
+	}{{
+		name: "pill",
+		html: "Hi " + pill("alice", "Alice"),
+		text: "Hi @alice",
+		uids: []string{"alice"},
+	}, {
+		name: "repeated pill",
+		html: pill("alice", "Alice") + " and " + pill("alice", "Alice") + " again",
+		text: "@alice and @alice again",
+		uids: []string{"alice"},
+	}, {
+		name: "pill of matrix user",
+		html: `Hi <a href="https://matrix.to/#/@dave:example.com">Dave</a>`,
+		text: "Hi Dave",
+	}, {
+		name:     "m.mentions limits pills",
+		html:     pill("alice", "Alice") + " and " + pill("bob", "Bob"),
+		mentions: &event.Mentions{UserIDs: []id.UserID{puppetMXID("bob")}},
+		text:     "Alice and @bob",
+		uids:     []string{"bob"},
+	}, {
+		name:     "m.mentions without pill",
+		html:     "Hi",
+		mentions: &event.Mentions{UserIDs: []id.UserID{puppetMXID("alice")}},
+		text:     "Hi",
+		uids:     []string{"alice"},
+	}, {
+		name:     "m.mentions of bridge user",
+		html:     "Hi",
+		mentions: &event.Mentions{UserIDs: []id.UserID{"@carol:example.com"}},
+		text:     "Hi",
+		uids:     []string{"carol"},
+	}, {
+		name:     "empty m.mentions",
+		html:     "Hi " + pill("alice", "Alice"),
+		mentions: &event.Mentions{},
+		text:     "Hi Alice",
+		uids:     []string{},
+	}, {
+		// Mentions used to be added by both m.mentions and the pills.
+		name: "pill and m.mentions are not appended twice",
+		html: pill("alice", "Alice") + " " + pill("alice", "Alice"),
+		mentions: &event.Mentions{UserIDs: []id.UserID{
+			puppetMXID("alice"), puppetMXID("alice"),
+		}},
+		text: "@alice @alice",
+		uids: []string{"alice"},
+	}, {
+		name: "displayname collision",
+		html: pill("alex1", "Alex") + " " + pill("alex2", "Alex"),
+		text: "@alex1 @alex2",
+		uids: []string{"alex1", "alex2"},
+	}, {
+		name: "displayname collision with m.mentions",
+		html: pill("alex1", "Alex") + " " + pill("alex2", "Alex"),
+		mentions: &event.Mentions{UserIDs: []id.UserID{
+			puppetMXID("alex2"), puppetMXID("alex1"),
+		}},
+		text: "@alex1 @alex2",
+		uids: []string{"alex2", "alex1"},
+	}, {
+		name: "markup",
+		html: "<b>bold</b> " + pill("alice", "Alice"),
+		mode: FormattingMarkup,
+		text: "*bold* @alice",
+		uids: []string{"alice"},
+	}, {
+		name: "plain",
+		html: "<b>bold</b><ul><li>item</li></ul>",
+		mode: FormattingPlain,
+		text: "bold\n• item",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mode := test.mode
+			if len(mode) == 0 {
+				mode = FormattingPlain
+			}
+
+			text, uids := br.Formatter.ParseMatrix(test.html, test.mentions, mode, zerolog.Nop())
+			if text != test.text {
+				t.Errorf("text %q, want %q", text, test.text)
+			}
+			if !slices.Equal(uids, test.uids) {
+				t.Errorf("mentioned uids %q, want %q", uids, test.uids)
+			}
+		})
+	}
+}
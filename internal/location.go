@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/duo/matrix-wechat/internal/coord"
+	"github.com/duo/matrix-wechat/internal/wechat"
+
+	"maunium.net/go/mautrix/event"
+)
+
+const (
+	MapProviderGoogle = "google"
+	MapProviderOSM    = "osm"
+	MapProviderAmap   = "amap"
+)
+
+var errInvalidGeoURI = errors.New("invalid geo URI")
+
+// toWGS84 converts WeChat coordinates for Matrix if conversion is enabled.
+func (br *WechatBridge) toWGS84(lat, lng float64) (float64, float64) {
+	if !br.Config.Bridge.Location.ConvertCoordinates {
+		return lat, lng
+	}
+
+	return coord.GCJToWGS(lat, lng)
+}
+
+// toGCJ02 converts Matrix coordinates for WeChat if conversion is enabled.
+func (br *WechatBridge) toGCJ02(lat, lng float64) (float64, float64) {
+	if !br.Config.Bridge.Location.ConvertCoordinates {
+		return lat, lng
+	}
+
+	return coord.WGSToGCJ(lat, lng)
+}
+
+// mapURL links a WeChat location on the configured map provider. Amap gets
+// the original GCJ-02 coordinates, the others the converted ones.
+func (br *WechatBridge) mapURL(data *wechat.LocationData, lat, lng float64) string {
+	switch br.Config.Bridge.Location.MapProvider {
+	case MapProviderOSM:
+		return fmt.Sprintf("https://www.openstreetmap.org/?mlat=%.5f&mlon=%.5f#map=16/%.5f/%.5f", lat, lng, lat, lng)
+	case MapProviderAmap:
+		query := url.Values{}
+		query.Set("position", fmt.Sprintf("%.6f,%.6f", data.Longitude, data.Latitude))
+		query.Set("name", data.Name)
+		query.Set("coordinate", "gaode")
+		return "https://uri.amap.com/marker?" + query.Encode()
+	default:
+		return fmt.Sprintf("https://maps.google.com/?q=%.5f,%.5f", lat, lng)
+	}
+}
+
+// parseGeoURI extracts latitude and longitude from a RFC 5870 geo URI.
+func parseGeoURI(uri string) (float64, float64, error) {
+	if !strings.HasPrefix(uri, "geo:") {
+		return 0, 0, errInvalidGeoURI
+	}
+	coords := strings.TrimPrefix(uri, "geo:")
+	if index := strings.IndexAny(coords, ";?"); index >= 0 {
+		coords = coords[:index]
+	}
+
+	parts := strings.Split(coords, ",")
+	if len(parts) < 2 {
+		return 0, 0, errInvalidGeoURI
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return 0, 0, errInvalidGeoURI
+	}
+	lng, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || lng < -180 || lng > 180 {
+		return 0, 0, errInvalidGeoURI
+	}
+
+	return lat, lng, nil
+}
+
+// convertMatrixLocation turns a m.location event into WeChat location data.
+// Name and address are taken from the body, which bridges usually format as
+// "Location: <name>\n<address>".
+func convertMatrixLocation(content *event.MessageEventContent, raw map[string]interface{}) (*wechat.LocationData, error) {
+	lat, lng, err := parseGeoURI(content.GeoURI)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &wechat.LocationData{
+		Latitude:  lat,
+		Longitude: lng,
+	}
+
+	lines := strings.Split(strings.TrimSpace(content.Body), "\n")
+	isDetail := func(line string) bool {
+		return len(line) > 0 && !strings.Contains(line, "geo:") && !strings.HasPrefix(line, "http")
+	}
+	if name := strings.TrimSpace(strings.TrimPrefix(lines[0], "Location:")); isDetail(name) {
+		data.Name = name
+	}
+	if len(lines) > 1 {
+		if address := strings.TrimSpace(lines[1]); isDetail(address) {
+			data.Address = address
+		}
+	}
+	if location, ok := raw["org.matrix.msc3488.location"].(map[string]interface{}); ok {
+		if description, ok := location["description"].(string); ok && len(description) > 0 {
+			data.Name = description
+		}
+	}
+	if len(data.Name) == 0 {
+		data.Name = "Location"
+	}
+
+	return data, nil
+}
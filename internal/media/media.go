@@ -0,0 +1,160 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os/exec"
+	"strings"
+	"time"
+
+	"golang.org/x/image/draw"
+
+	_ "image/gif"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// ThumbnailSize is the maximum width or height of generated thumbnails.
+	ThumbnailSize = 800
+	// ThumbnailMimeType is the mime type of generated thumbnails.
+	ThumbnailMimeType = "image/jpeg"
+
+	thumbnailQuality = 80
+	frameTimeout     = 30 * time.Second
+	convertTimeout   = time.Minute
+)
+
+// Info describes the media metadata which Matrix clients use for rendering.
+type Info struct {
+	Width    int
+	Height   int
+	Duration time.Duration
+}
+
+// Thumbnail is a JPEG preview of an image or video.
+type Thumbnail struct {
+	Data   []byte
+	Width  int
+	Height int
+}
+
+// Probe returns the dimensions and duration of images and MP4 videos.
+func Probe(data []byte, mimeType string) Info {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return Info{}
+		}
+		return Info{Width: cfg.Width, Height: cfg.Height}
+	case strings.HasPrefix(mimeType, "video/"):
+		info, _ := probeMP4(data)
+		return info
+	default:
+		return Info{}
+	}
+}
+
+// MakeThumbnail creates a JPEG thumbnail for images larger than ThumbnailSize
+// and for the first frame of videos. It returns nil if no thumbnail is needed.
+func MakeThumbnail(data []byte, mimeType string) (*Thumbnail, error) {
+	switch {
+	case mimeType == "image/gif":
+		// Animated images are shown as is.
+		return nil, nil
+	case strings.HasPrefix(mimeType, "image/"):
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode image: %w", err)
+		}
+		bounds := img.Bounds()
+		if bounds.Dx() <= ThumbnailSize && bounds.Dy() <= ThumbnailSize {
+			return nil, nil
+		}
+		return encodeThumbnail(img)
+	case strings.HasPrefix(mimeType, "video/"):
+		frame, err := extractFrame(data)
+		if err != nil {
+			return nil, err
+		}
+		img, _, err := image.Decode(bytes.NewReader(frame))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode video frame: %w", err)
+		}
+		return encodeThumbnail(img)
+	default:
+		return nil, nil
+	}
+}
+
+func encodeThumbnail(img image.Image) (*Thumbnail, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > ThumbnailSize || height > ThumbnailSize {
+		if width > height {
+			width, height = ThumbnailSize, height*ThumbnailSize/width
+		} else {
+			width, height = width*ThumbnailSize/height, ThumbnailSize
+		}
+		if width == 0 {
+			width = 1
+		}
+		if height == 0 {
+			height = 1
+		}
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.ApproxBiLinear.Scale(scaled, scaled.Bounds(), img, bounds, draw.Src, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+
+	return &Thumbnail{Data: buf.Bytes(), Width: width, Height: height}, nil
+}
+
+// extractFrame grabs the first video frame as JPEG through ffmpeg.
+func extractFrame(data []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), frameTimeout)
+	defer cancel()
+
+	frame, err := runPipe(ctx, data, "ffmpeg",
+		"-hide_banner", "-loglevel", "error",
+		"-i", "pipe:0", "-frames:v", "1", "-f", "image2", "-c:v", "mjpeg", "pipe:1")
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract video frame: %w", err)
+	}
+
+	return frame, nil
+}
+
+// runPipe feeds data to an external tool through stdin and returns its stdout.
+func runPipe(ctx context.Context, data []byte, name string, args ...string) ([]byte, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not installed: %w", name, err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); len(msg) > 0 {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	} else if stdout.Len() == 0 {
+		return nil, fmt.Errorf("%s produced no output", name)
+	}
+
+	return stdout.Bytes(), nil
+}
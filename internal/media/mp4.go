@@ -0,0 +1,94 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+var errInvalidMP4 = errors.New("invalid mp4 data")
+
+// probeMP4 reads the duration from the movie header and the dimensions from the first
+// visual track header, without decoding any frames.
+func probeMP4(data []byte) (Info, error) {
+	var info Info
+	found := false
+
+	var walk func(data []byte) error
+	walk = func(data []byte) error {
+		for len(data) >= 8 {
+			size := uint64(binary.BigEndian.Uint32(data))
+			boxType := string(data[4:8])
+			header := uint64(8)
+			switch size {
+			case 0:
+				size = uint64(len(data))
+			case 1:
+				if len(data) < 16 {
+					return errInvalidMP4
+				}
+				size = binary.BigEndian.Uint64(data[8:])
+				header = 16
+			}
+			if size < header || size > uint64(len(data)) {
+				return errInvalidMP4
+			}
+			body := data[header:size]
+
+			switch boxType {
+			case "moov", "trak":
+				if err := walk(body); err != nil {
+					return err
+				}
+			case "mvhd":
+				found = true
+				info.Duration = parseMovieHeader(body)
+			case "tkhd":
+				if info.Width == 0 && info.Height == 0 {
+					info.Width, info.Height = parseTrackHeader(body)
+				}
+			}
+
+			data = data[size:]
+		}
+
+		return nil
+	}
+
+	if err := walk(data); err != nil {
+		return info, err
+	} else if !found {
+		return info, errInvalidMP4
+	}
+
+	return info, nil
+}
+
+func parseMovieHeader(body []byte) time.Duration {
+	var timescale, duration uint64
+	if len(body) >= 32 && body[0] == 1 {
+		timescale = uint64(binary.BigEndian.Uint32(body[20:]))
+		duration = binary.BigEndian.Uint64(body[24:])
+	} else if len(body) >= 20 {
+		timescale = uint64(binary.BigEndian.Uint32(body[12:]))
+		duration = uint64(binary.BigEndian.Uint32(body[16:]))
+	}
+	if timescale == 0 {
+		return 0
+	}
+
+	return time.Duration(duration * uint64(time.Second) / timescale)
+}
+
+func parseTrackHeader(body []byte) (int, int) {
+	// Width and height are 16.16 fixed point values at the end of the box.
+	offset := 76
+	if len(body) > 0 && body[0] == 1 {
+		offset = 88
+	}
+	if len(body) < offset+8 {
+		return 0, 0
+	}
+
+	return int(binary.BigEndian.Uint32(body[offset:]) >> 16), int(binary.BigEndian.Uint32(body[offset+4:]) >> 16)
+}
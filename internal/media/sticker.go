@@ -0,0 +1,146 @@
+package media
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// StickerSize is the maximum width or height of converted animated stickers.
+const StickerSize = 256
+
+var (
+	ErrUnsupportedSticker = errors.New("unsupported sticker format")
+	// ErrNoLottieConverter is returned for Lottie stickers if lottieconverter isn't installed.
+	ErrNoLottieConverter = errors.New("lottieconverter is not installed")
+)
+
+var lottieConverterPath string
+
+// FindLottieConverter looks up lottieconverter in PATH, which renders Lottie
+// stickers. It's called once at startup and reports whether it was found.
+func FindLottieConverter() bool {
+	lottieConverterPath, _ = exec.LookPath("lottieconverter")
+
+	return len(lottieConverterPath) > 0
+}
+
+// ToWechatSticker converts a Matrix sticker into an image which can be sent
+// as WeChat custom emoticon. Animated stickers (animated WebP, WebM and
+// Lottie) become GIFs, static WebP becomes PNG.
+func ToWechatSticker(data []byte, mimeType string) ([]byte, string, error) {
+	switch {
+	case mimeType == "image/gif", mimeType == "image/png", mimeType == "image/jpeg":
+		return data, mimeType, nil
+	case mimeType == "image/webp" && !isAnimatedWebP(data):
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to decode webp: %w", err)
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, "", fmt.Errorf("failed to encode png: %w", err)
+		}
+		return buf.Bytes(), "image/png", nil
+	case isLottieMime(mimeType) || isGzip(data):
+		gif, err := lottieToGIF(data)
+		if err != nil {
+			return nil, "", err
+		}
+		return gif, "image/gif", nil
+	case mimeType == "image/webp", strings.HasPrefix(mimeType, "video/"):
+		gif, err := toGIF(data)
+		if err != nil {
+			return nil, "", err
+		}
+		return gif, "image/gif", nil
+	default:
+		return nil, "", fmt.Errorf("%w: %s", ErrUnsupportedSticker, mimeType)
+	}
+}
+
+// ToMatrixSticker makes sure a WeChat emoticon can be rendered by Matrix
+// clients, converting anything that isn't a common image format to GIF.
+func ToMatrixSticker(data []byte, mimeType string) ([]byte, string, error) {
+	switch mimeType {
+	case "image/gif", "image/png", "image/jpeg", "image/webp":
+		return data, mimeType, nil
+	default:
+		gif, err := toGIF(data)
+		if err != nil {
+			return nil, "", err
+		}
+		return gif, "image/gif", nil
+	}
+}
+
+func toGIF(data []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), convertTimeout)
+	defer cancel()
+
+	filter := fmt.Sprintf(
+		"fps=15,scale=%d:%d:force_original_aspect_ratio=decrease:flags=lanczos,"+
+			"split[a][b];[a]palettegen=reserve_transparent=1[p];[b][p]paletteuse",
+		StickerSize, StickerSize,
+	)
+	gif, err := runPipe(ctx, data, "ffmpeg",
+		"-hide_banner", "-loglevel", "error",
+		"-i", "pipe:0", "-vf", filter, "-loop", "0", "-f", "gif", "pipe:1")
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert sticker to gif: %w", err)
+	}
+
+	return gif, nil
+}
+
+// lottieToGIF renders Lottie animations (plain or gzipped like Telegram's tgs)
+// through lottieconverter.
+func lottieToGIF(data []byte) ([]byte, error) {
+	if len(lottieConverterPath) == 0 {
+		return nil, ErrNoLottieConverter
+	}
+
+	if isGzip(data) {
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress lottie: %w", err)
+		}
+		defer reader.Close()
+		if data, err = io.ReadAll(reader); err != nil {
+			return nil, fmt.Errorf("failed to decompress lottie: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), convertTimeout)
+	defer cancel()
+
+	gif, err := runPipe(ctx, data, lottieConverterPath,
+		"-", "-", "gif", fmt.Sprintf("%dx%d", StickerSize, StickerSize), "25")
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert lottie to gif: %w", err)
+	}
+
+	return gif, nil
+}
+
+func isLottieMime(mimeType string) bool {
+	return mimeType == "video/lottie+json" || mimeType == "application/x-tgsticker" || mimeType == "application/json"
+}
+
+func isGzip(data []byte) bool {
+	return len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b
+}
+
+// isAnimatedWebP checks the animation flag of extended (VP8X) WebP files.
+func isAnimatedWebP(data []byte) bool {
+	return len(data) > 20 &&
+		string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP" &&
+		string(data[12:16]) == "VP8X" && data[20]&0x02 != 0
+}
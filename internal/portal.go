@@ -1,20 +1,22 @@
 package internal
 
 import (
-	"bytes"
 	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"image"
+	"path/filepath"
+	"regexp"
 	"runtime/debug"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/duo/matrix-wechat/internal/audio"
 	"github.com/duo/matrix-wechat/internal/database"
+	"github.com/duo/matrix-wechat/internal/media"
 	"github.com/duo/matrix-wechat/internal/types"
 	"github.com/duo/matrix-wechat/internal/wechat"
 
@@ -28,16 +30,12 @@ import (
 	"maunium.net/go/mautrix/bridge/bridgeconfig"
 	"maunium.net/go/mautrix/crypto/attachment"
 	"maunium.net/go/mautrix/event"
-	"maunium.net/go/mautrix/format"
 	"maunium.net/go/mautrix/id"
-
-	_ "image/gif"
-	_ "image/jpeg"
-	_ "image/png"
 )
 
 const (
 	PrivateChatTopic      = "WeChat private chat"
+	wechatMentionAllText  = "所有人"
 	recentlyHandledLength = 100
 )
 
@@ -47,6 +45,9 @@ var (
 	errUserNotLoggedIn         = errors.New("user is not logged in")
 	errMediaDownloadFailed     = errors.New("failed to download media")
 	errMediaDecryptFailed      = errors.New("failed to decrypt media")
+	errPortalAlreadyBridged    = errors.New("portal is already bridged to another room")
+
+	roomMentionRegex = regexp.MustCompile(`(^|\s)@room\b`)
 
 	PortalCreationDummyEvent = event.Type{Type: "me.lxduo.wechat.dummy.portal_created", Class: event.MessageEventType}
 )
@@ -79,6 +80,8 @@ type Portal struct {
 
 	messages       chan PortalMessage
 	matrixMessages chan PortalMatrixMessage
+
+	relayUser *User
 }
 
 type ReplyInfo struct {
@@ -96,6 +99,11 @@ type ConvertedMessage struct {
 	ReplyTo  *ReplyInfo
 	Error    database.MessageErrorType
 	MediaKey []byte
+
+	// Additional events bridged from the same WeChat message.
+	MultiEvent []*ConvertedMessage
+	// Send as thread reply to the first event of the message.
+	Thread bool
 }
 
 type fakeMessage struct {
@@ -122,11 +130,46 @@ func (p *Portal) MarkEncrypted() {
 }
 
 func (p *Portal) ReceiveMatrixEvent(user bridge.User, evt *event.Event) {
-	if user.GetPermissionLevel() >= bridgeconfig.PermissionLevelUser {
+	if user.GetPermissionLevel() >= bridgeconfig.PermissionLevelRelay {
 		p.matrixMessages <- PortalMatrixMessage{user: user.(*User), evt: evt, receivedAt: time.Now()}
 	}
 }
 
+// formattingMode returns how Matrix formatting is sent to WeChat in this portal.
+func (p *Portal) formattingMode() string {
+	if len(p.Formatting) > 0 {
+		return p.Formatting
+	} else if len(p.bridge.Config.Bridge.OutboundFormatting) > 0 {
+		return p.bridge.Config.Bridge.OutboundFormatting
+	}
+
+	return FormattingPlain
+}
+
+func (p *Portal) HasRelaybot() bool {
+	return p.bridge.Config.Bridge.Relay.Enabled && len(p.RelayUserID) > 0
+}
+
+func (p *Portal) GetRelayUser() *User {
+	if !p.HasRelaybot() {
+		return nil
+	} else if p.relayUser == nil || p.relayUser.MXID != p.RelayUserID {
+		p.relayUser = p.bridge.GetUserByMXID(p.RelayUserID)
+	}
+
+	return p.relayUser
+}
+
+func (p *Portal) SetRelayUser(user *User) {
+	if user == nil {
+		p.RelayUserID = ""
+	} else {
+		p.RelayUserID = user.MXID
+	}
+	p.relayUser = user
+	p.Update(nil)
+}
+
 func (p *Portal) GetUsers() []*User {
 	return nil
 }
@@ -140,6 +183,10 @@ func (p *Portal) handleWechatMessageLoopItem(msg PortalMessage) {
 	}()
 
 	if len(p.MXID) == 0 {
+		if p.Unbridged {
+			p.log.Debug().Msgf("Dropping incoming message, the portal was unbridged")
+			return
+		}
 		p.log.Debug().Msgf("Creating Matrix room from incoming message")
 		err := p.CreateMatrixRoom(msg.source, nil, false)
 		if err != nil {
@@ -243,22 +290,23 @@ func (p *Portal) handleWechatRevoke(source *User, message *wechat.Event) {
 		return
 	}
 
-	msg := p.bridge.DB.Message.GetByMsgID(p.Key, msgID)
-	if msg == nil || msg.IsFakeMXID() {
-		return
-	}
-
 	intent := p.bridge.GetPuppetByUID(types.NewUserUID(message.From.ID)).IntentFor(p)
-	_, err := intent.RedactEvent(p.MXID, msg.MXID)
-	if err != nil {
-		if errors.Is(err, mautrix.MForbidden) {
-			_, err = p.MainIntent().RedactEvent(p.MXID, msg.MXID)
-			if err != nil {
-				p.log.Error().Msgf("Failed to redact %s: %v", msg.MsgID, err)
+	for _, msg := range p.bridge.DB.Message.GetAllParts(p.Key, msgID) {
+		if msg.IsFakeMXID() {
+			continue
+		}
+
+		_, err := intent.RedactEvent(p.MXID, msg.MXID)
+		if err != nil {
+			if errors.Is(err, mautrix.MForbidden) {
+				_, err = p.MainIntent().RedactEvent(p.MXID, msg.MXID)
+				if err != nil {
+					p.log.Error().Msgf("Failed to redact %s: %v", msg.MsgID, err)
+				}
 			}
+			//} else {
+			//msg.Delete()
 		}
-		//} else {
-		//msg.Delete()
 	}
 }
 
@@ -302,7 +350,9 @@ func (p *Portal) handleWechatEvent(source *User, msg *wechat.Event) {
 	switch msg.Type {
 	case wechat.EventText:
 		converted = p.convertWechatText(source, msg, intent)
-	case wechat.EventPhoto, wechat.EventSticker, wechat.EventVideo, wechat.EventAudio, wechat.EventFile:
+	case wechat.EventPhoto:
+		converted = p.convertWechatPhotos(source, msg, intent)
+	case wechat.EventSticker, wechat.EventVideo, wechat.EventAudio, wechat.EventFile:
 		converted = p.convertWechatMedia(source, msg, intent)
 	case wechat.EventLocation:
 		converted = p.convertWechatLocation(source, msg, intent)
@@ -311,6 +361,9 @@ func (p *Portal) handleWechatEvent(source *User, msg *wechat.Event) {
 	case wechat.EventApp:
 		converted = p.convertWechatApp(source, msg, intent)
 	case wechat.EventVoIP, wechat.EventSystem:
+		if data, ok := msg.Data.(*wechat.SystemData); ok && data != nil {
+			p.handleWechatSystem(source, data)
+		}
 		p.handleFakeMessage(fakeMessage{
 			Sender:    sender,
 			Text:      msg.Content,
@@ -328,56 +381,61 @@ func (p *Portal) handleWechatEvent(source *User, msg *wechat.Event) {
 		})
 	}
 
-	var eventID id.EventID
-	resp, err := p.sendMessage(converted.Intent, converted.Type, converted.Content, converted.Extra, ts)
-	if err != nil {
-		p.log.Error().Msgf("Failed to send %s to Matrix: %v", msgID, err)
-	} else {
-		eventID = resp.EventID
-	}
+	parts := append([]*ConvertedMessage{converted}, converted.MultiEvent...)
+	var threadRoot, lastEventID id.EventID
+	for index, part := range parts {
+		partID := database.PartMsgID(msgID, index)
+		if part.Thread && len(threadRoot) > 0 {
+			part.Content.RelatesTo = (&event.RelatesTo{}).SetThread(threadRoot, lastEventID)
+		}
 
-	if len(eventID) != 0 {
-		p.finishHandling(existingMsg, msgID, time.UnixMilli(ts), sender, eventID, database.MsgNormal, converted.Error)
+		var eventID id.EventID
+		resp, err := p.sendMessage(part.Intent, part.Type, part.Content, part.Extra, ts)
+		if err != nil {
+			p.log.Error().Msgf("Failed to send %s to Matrix: %v", partID, err)
+		} else {
+			eventID = resp.EventID
+		}
+
+		if len(eventID) != 0 {
+			var existingPart *database.Message
+			if index == 0 {
+				existingPart = existingMsg
+				threadRoot = eventID
+			}
+			lastEventID = eventID
+			p.finishHandling(existingPart, partID, time.UnixMilli(ts), sender, eventID, database.MsgNormal, part.Error)
+		}
 	}
 }
 
 func (p *Portal) convertWechatText(source *User, msg *wechat.Event, intent *appservice.IntentAPI) *ConvertedMessage {
-	var content *event.MessageEventContent
-
-	emotionCotent := ReplaceEmotion(msg.Content)
-
-	content = &event.MessageEventContent{
-		Body:    emotionCotent,
-		MsgType: event.MsgText,
+	var mentions []WechatMention
+	mentionAll := false
+	for _, mention := range msg.Mentions {
+		if mention == wechat.MentionAll {
+			mentionAll = true
+			continue
+		}
+		mxid, name := p.bridge.Formatter.GetMatrixInfoByUID(p.MXID, types.NewUserUID(mention))
+		mentions = append(mentions, WechatMention{
+			MXID:        mxid,
+			Name:        source.Client.GetGroupMemberNickname(p.Key.UID.Uin, mention),
+			Displayname: name,
+		})
 	}
 
-	if len(msg.Mentions) > 0 {
-		formattedBody := emotionCotent
-		var formattedHead string
-
-		// TODO: notify all?
-		for _, mention := range msg.Mentions {
-			mxid, name := p.bridge.Formatter.GetMatrixInfoByUID(p.MXID, types.NewUserUID(mention))
-			groupNickname := source.Client.GetGroupMemberNickname(p.Key.UID.Uin, mention)
-			original := "@" + groupNickname
-			replacement := fmt.Sprintf(`<a href="https://matrix.to/#/%s">%s</a> `, mxid, name)
-
-			if len(groupNickname) > 0 && strings.Contains(emotionCotent, original) {
-				formattedBody = strings.ReplaceAll(formattedBody, original, replacement)
-			} else {
-				formattedHead += replacement
-			}
-		}
-		if len(formattedHead) > 0 {
-			formattedHead += "<br> "
-		}
+	text := msg.Content
+	if mentionAll {
+		text = strings.ReplaceAll(text, "@"+wechatMentionAllText, "@room")
+	}
 
-		content = &event.MessageEventContent{
-			MsgType:       event.MsgText,
-			Format:        event.FormatHTML,
-			Body:          msg.Content,
-			FormattedBody: formattedHead + formattedBody,
+	content := p.bridge.Formatter.ParseWechat(text, mentions)
+	if mentionAll {
+		if content.Mentions == nil {
+			content.Mentions = &event.Mentions{}
 		}
+		content.Mentions.Room = true
 	}
 
 	converted := &ConvertedMessage{
@@ -389,33 +447,62 @@ func (p *Portal) convertWechatText(source *User, msg *wechat.Event, intent *apps
 	return converted
 }
 
-func (p *Portal) convertWechatMedia(source *User, msg *wechat.Event, intent *appservice.IntentAPI) *ConvertedMessage {
+func (p *Portal) convertWechatPhotos(source *User, msg *wechat.Event, intent *appservice.IntentAPI) *ConvertedMessage {
 	msgID := fmt.Sprint(msg.ID)
 
+	blobs, _ := msg.Data.([]*wechat.BlobData)
+	if len(blobs) == 0 {
+		return p.makeMediaBridgeFailureMessage(msgID, errors.New("photo message has no images"), &ConvertedMessage{Intent: intent})
+	}
+
+	converted := p.convertWechatBlob(msgID, msg.Type, blobs[0], intent)
+	for index, data := range blobs[1:] {
+		partID := database.PartMsgID(msgID, index+1)
+		converted.MultiEvent = append(converted.MultiEvent, p.convertWechatBlob(partID, msg.Type, data, intent))
+	}
+
+	return converted
+}
+
+func (p *Portal) convertWechatMedia(source *User, msg *wechat.Event, intent *appservice.IntentAPI) *ConvertedMessage {
+	return p.convertWechatBlob(fmt.Sprint(msg.ID), msg.Type, msg.Data.(*wechat.BlobData), intent)
+}
+
+func (p *Portal) convertWechatBlob(msgID string, msgType wechat.EventType, data *wechat.BlobData, intent *appservice.IntentAPI) *ConvertedMessage {
 	converted := &ConvertedMessage{
 		Intent: intent,
 	}
 
-	var err error
-	var data *wechat.BlobData
-
-	if msg.Type == wechat.EventPhoto {
-		data = msg.Data.([]*wechat.BlobData)[0]
-	} else {
-		data = msg.Data.(*wechat.BlobData)
+	if limit := p.bridge.MaxMediaSize(); limit > 0 && int64(len(data.Binary)) > limit {
+		return p.makeOversizeMessage(msgID, data, converted)
 	}
 
+	var err error
+
 	binary := data.Binary
-	if msg.Type == wechat.EventAudio {
-		if binary, err = silk2ogg(data.Binary); err != nil {
-			return p.makeMediaBridgeFailureMessage(msgID, errors.New("failed to convert silk audio to ogg format"), converted)
+	var voice *audio.Voice
+	if msgType == wechat.EventAudio {
+		if voice, err = p.bridge.Audio.SilkToMatrix(context.Background(), data.Binary); err != nil {
+			return p.makeMediaBridgeFailureMessage(msgID, fmt.Errorf("failed to convert silk audio: %w", err), converted)
 		}
+		binary = voice.Data
 	}
 
 	mime := mimetype.Detect(binary)
 
+	isSticker := false
+	if msgType == wechat.EventSticker {
+		if sticker, _, err := media.ToMatrixSticker(binary, mime.String()); err != nil {
+			p.log.Warn().Msgf("Failed to convert sticker %s, sending as image: %v", msgID, err)
+		} else {
+			binary = sticker
+			mime = mimetype.Detect(binary)
+			isSticker = true
+		}
+	}
+
 	content := &event.MessageEventContent{
-		MsgType: wechat.ToMessageType(msg.Type),
+		MsgType: wechat.ToMessageType(msgType),
 		Info: &event.FileInfo{
 			MimeType: mime.String(),
 			Size:     len(binary),
@@ -425,6 +512,27 @@ func (p *Portal) convertWechatMedia(source *User, msg *wechat.Event, intent *app
 	converted.Type = event.EventMessage
 	converted.Content = content
 
+	if isSticker {
+		// Stickers are a separate event type without msgtype.
+		converted.Type = event.EventSticker
+		content.MsgType = ""
+	}
+
+	if voice != nil {
+		duration := int(voice.Duration.Milliseconds())
+		content.Info.Duration = duration
+		converted.Extra = map[string]interface{}{
+			"org.matrix.msc1767.audio": map[string]interface{}{
+				"duration": duration,
+				"waveform": voice.Waveform,
+			},
+		}
+		// Clients only play Ogg/Opus as voice messages, WAV is sent as a normal audio file.
+		if voice.Format == audio.FormatOgg {
+			converted.Extra["org.matrix.msc3245.voice"] = map[string]interface{}{}
+		}
+	}
+
 	err = p.uploadMedia(intent, binary, content)
 	if err != nil {
 		if errors.Is(err, mautrix.MTooLarge) {
@@ -446,42 +554,17 @@ func (p *Portal) convertWechatLocation(source *User, msg *wechat.Event, intent *
 
 	data := msg.Data.(*wechat.LocationData)
 
-	url := fmt.Sprintf("https://maps.google.com/?q=%.5f,%.5f", data.Latitude, data.Longitude)
+	lat, lng := p.bridge.toWGS84(data.Latitude, data.Longitude)
+	url := p.bridge.mapURL(data, lat, lng)
 
 	content := &event.MessageEventContent{
 		MsgType:       event.MsgLocation,
 		Body:          fmt.Sprintf("Location: %s\n%s\n%s", data.Name, data.Address, url),
 		Format:        event.FormatHTML,
 		FormattedBody: fmt.Sprintf("Location: <a href='%s'>%s</a><br>%s", url, data.Name, data.Address),
-		GeoURI:        fmt.Sprintf("geo:%.5f,%.5f", data.Latitude, data.Longitude),
-	}
-
-	converted.Type = event.EventMessage
-	converted.Content = content
-
-	return converted
-}
-
-func (p *Portal) convertWechatApp(source *User, msg *wechat.Event, intent *appservice.IntentAPI) *ConvertedMessage {
-	converted := &ConvertedMessage{
-		Intent: intent,
+		GeoURI:        fmt.Sprintf("geo:%.5f,%.5f", lat, lng),
 	}
 
-	data := msg.Data.(*wechat.AppData)
-
-	var body string
-	if len(data.URL) > 0 {
-		body = fmt.Sprintf("[%s](%s)\n%s", data.Title, data.URL, data.Description)
-	} else {
-		body = fmt.Sprintf("**%s**\n%s", data.Title, data.Description)
-	}
-
-	content := &event.MessageEventContent{
-		MsgType:       event.MsgText,
-		Format:        event.FormatHTML,
-		Body:          body,
-		FormattedBody: format.RenderMarkdown(body, true, false).FormattedBody,
-	}
 	converted.Type = event.EventMessage
 	converted.Content = content
 
@@ -535,7 +618,9 @@ func (p *Portal) getMessagePuppet(user *User, sender types.UID) *Puppet {
 	}
 
 	user.EnqueuePortalResync(p)
-	puppet.SyncContact(user, false, "handling message")
+	if contact, remarkChanged := puppet.SyncContact(user, false, "handling message"); remarkChanged {
+		go user.updateRemarkDisplaynames(map[*Puppet]*types.ContactInfo{puppet: contact})
+	}
 
 	return puppet
 }
@@ -577,7 +662,7 @@ func (p *Portal) kickExtraUsers(participantMap map[types.UID]bool) {
 	}
 }
 
-func (p *Portal) syncParticipant(source *User, participant string, puppet *Puppet, user *User, forceAvatarSync bool, wg *sync.WaitGroup) {
+func (p *Portal) syncParticipant(source *User, participant, groupNickname string, puppet *Puppet, user *User, forceAvatarSync bool, changedRemarks *remarkChanges, wg *sync.WaitGroup) {
 	defer func() {
 		wg.Done()
 		if err := recover(); err != nil {
@@ -585,8 +670,10 @@ func (p *Portal) syncParticipant(source *User, participant string, puppet *Puppe
 		}
 	}()
 
-	puppet.SyncContact(source, forceAvatarSync, "group participant")
-	p.UpdateRoomNickname(source, participant)
+	contact, remarkChanged := puppet.SyncContact(source, forceAvatarSync, "group participant")
+	if remarkChanged {
+		changedRemarks.add(puppet, contact)
+	}
 	if user != nil && user != source {
 		p.ensureUserInvited(user)
 	}
@@ -596,6 +683,7 @@ func (p *Portal) syncParticipant(source *User, participant string, puppet *Puppe
 			p.log.Warn().Msgf("Failed to make puppet of %s join %s: %v", participant, p.MXID, err)
 		}
 	}
+	p.UpdateRoomNickname(puppet, contact, groupNickname)
 }
 
 func (p *Portal) SyncParticipants(source *User, metadata *wechat.GroupInfo, forceAvatarSync bool) {
@@ -619,16 +707,23 @@ func (p *Portal) SyncParticipants(source *User, metadata *wechat.GroupInfo, forc
 	var wg sync.WaitGroup
 	wg.Add(len(metadata.Members))
 	participantMap := make(map[types.UID]bool)
+	changedRemarks := &remarkChanges{}
 	for _, participant := range metadata.Members {
 		uid := types.NewUserUID(participant)
 		participantMap[uid] = true
 		puppet := p.bridge.GetPuppetByUID(uid)
 		user := p.bridge.GetUserByUID(uid)
 
+		// Agents which don't report nicknames in bulk need one request per member.
+		groupNickname, ok := metadata.Nicknames[participant]
+		if !ok && metadata.Nicknames == nil {
+			groupNickname = source.Client.GetGroupMemberNickname(metadata.ID, participant)
+		}
+
 		if p.bridge.Config.Bridge.ParallelMemberSync {
-			go p.syncParticipant(source, participant, puppet, user, forceAvatarSync, &wg)
+			go p.syncParticipant(source, participant, groupNickname, puppet, user, forceAvatarSync, changedRemarks, &wg)
 		} else {
-			p.syncParticipant(source, participant, puppet, user, forceAvatarSync, &wg)
+			p.syncParticipant(source, participant, groupNickname, puppet, user, forceAvatarSync, changedRemarks, &wg)
 		}
 
 		expectedLevel := 0
@@ -648,21 +743,54 @@ func (p *Portal) SyncParticipants(source *User, metadata *wechat.GroupInfo, forc
 
 	p.kickExtraUsers(participantMap)
 	wg.Wait()
+	source.updateRemarkDisplaynames(changedRemarks.contacts)
 	p.log.Debug().Msgf("Participant sync completed")
 }
 
-func (p *Portal) UpdateRoomNickname(source *User, wxid string) {
-	groupNickname := source.Client.GetGroupMemberNickname(p.Key.UID.Uin, wxid)
-	if len(groupNickname) <= 0 {
-		return
+func (p *Portal) handleWechatSystem(source *User, data *wechat.SystemData) {
+	switch data.Type {
+	case wechat.SystemGroupNickname:
+		if !p.IsGroupChat() || len(data.Member) == 0 {
+			return
+		}
+		puppet := p.bridge.GetPuppetByUID(types.NewUserUID(data.Member))
+		var contact *types.ContactInfo
+		if info := source.Client.GetUserInfo(data.Member); info != nil {
+			contact = types.NewContact(info.ID, info.Name, info.Remark)
+		}
+		p.log.Debug().Msgf("Group nickname of %s changed to %q", data.Member, data.Nickname)
+		p.UpdateRoomNickname(puppet, contact, data.Nickname)
 	}
+}
 
-	puppet := p.bridge.GetPuppetByUID(types.NewUserUID(wxid))
+// UpdateRoomNickname sets the per-room displayname of a puppet. The remark the
+// portal receiver has set for the contact takes precedence over the group
+// nickname, puppets with neither get their global displayname back.
+func (p *Portal) UpdateRoomNickname(puppet *Puppet, contact *types.ContactInfo, groupNickname string) {
+	var info types.ContactInfo
+	if contact != nil {
+		info = *contact
+	} else {
+		info = *types.NewContact(puppet.UID.Uin, puppet.UID.Uin, "")
+	}
+	// Remarks are private, only the one of the portal receiver may be used.
+	info.Remark = ""
+	if receiver := p.bridge.GetUserByUID(p.Key.Receiver); receiver != nil {
+		info.Remark = receiver.GetContactRemark(puppet.UID.Uin)
+	}
+
+	roomNickname := p.bridge.Config.Bridge.FormatRemarkDisplayname(info)
+	if len(roomNickname) == 0 {
+		roomNickname = p.bridge.Config.Bridge.FormatRoomDisplayname(info, groupNickname)
+	}
+	if len(roomNickname) == 0 {
+		roomNickname = puppet.Displayname
+	}
 
-	roomNickname, _ := p.bridge.Config.Bridge.FormatDisplayname(
-		*types.NewContact(wxid, groupNickname, groupNickname),
-	)
 	memberContent := puppet.IntentFor(p).Member(p.MXID, puppet.MXID)
+	if memberContent == nil || memberContent.Membership != event.MembershipJoin {
+		return
+	}
 	if memberContent.Displayname != roomNickname {
 		memberContent.Displayname = roomNickname
 		if _, err := puppet.DefaultIntent().SendStateEvent(
@@ -987,6 +1115,18 @@ func (p *Portal) UpdateBridgeInfo() {
 	}
 }
 
+func (p *Portal) removeBridgeInfo() {
+	stateKey := p.getBridgeInfoStateKey()
+	_, err := p.MainIntent().SendStateEvent(p.MXID, event.StateBridge, stateKey, struct{}{})
+	if err != nil {
+		p.log.Warn().Msgf("Failed to remove m.bridge: %v", err)
+	}
+	_, err = p.MainIntent().SendStateEvent(p.MXID, event.StateHalfShotBridge, stateKey, struct{}{})
+	if err != nil {
+		p.log.Warn().Msgf("Failed to remove uk.half-shot.bridge: %v", err)
+	}
+}
+
 func (p *Portal) shouldSetDMRoomMetadata() bool {
 	return !p.IsPrivateChat() ||
 		p.bridge.Config.Bridge.PrivateChatPortalMeta == "always" ||
@@ -1017,9 +1157,15 @@ func (p *Portal) CreateMatrixRoom(user *User, groupInfo *wechat.GroupInfo, isFul
 
 	p.log.Info().Msgf("Creating Matrix room. Info source: %s", user.MXID)
 
+	var contact *types.ContactInfo
 	if p.IsPrivateChat() {
 		puppet := p.bridge.GetPuppetByUID(p.Key.UID)
-		puppet.SyncContact(user, true, "creating private chat portal")
+		var remarkChanged bool
+		contact, remarkChanged = puppet.SyncContact(user, true, "creating private chat portal")
+		if remarkChanged {
+			// The new portal itself gets the remark below, the update is for the group portals.
+			go user.updateRemarkDisplaynames(map[*Puppet]*types.ContactInfo{puppet: contact})
+		}
 		p.Name = puppet.Displayname
 		p.AvatarURL = puppet.AvatarURL
 		p.Avatar = puppet.Avatar
@@ -1120,6 +1266,7 @@ func (p *Portal) CreateMatrixRoom(user *User, groupInfo *wechat.GroupInfo, isFul
 	p.NameSet = len(p.Name) > 0
 	p.TopicSet = len(p.Topic) > 0
 	p.MXID = resp.RoomID
+	p.Unbridged = false
 	p.bridge.portalsLock.Lock()
 	p.bridge.portalsByMXID[p.MXID] = p
 	p.bridge.portalsLock.Unlock()
@@ -1149,6 +1296,7 @@ func (p *Portal) CreateMatrixRoom(user *User, groupInfo *wechat.GroupInfo, isFul
 		}
 
 		user.UpdateDirectChats(map[id.UserID][]id.RoomID{puppet.MXID: {p.MXID}})
+		p.UpdateRoomNickname(puppet, contact, "")
 	}
 
 	firstEventResp, err := p.MainIntent().SendMessageEvent(p.MXID, PortalCreationDummyEvent, struct{}{})
@@ -1162,6 +1310,69 @@ func (p *Portal) CreateMatrixRoom(user *User, groupInfo *wechat.GroupInfo, isFul
 	return nil
 }
 
+func (p *Portal) BridgeMatrixRoom(user *User, roomID id.RoomID, groupInfo *wechat.GroupInfo) error {
+	p.roomCreateLock.Lock()
+	defer p.roomCreateLock.Unlock()
+
+	if len(p.MXID) > 0 {
+		return errPortalAlreadyBridged
+	}
+
+	p.log.Info().Msgf("Bridging existing room %s. Info source: %s", roomID, user.MXID)
+
+	var existingEncryption event.EncryptionEventContent
+	err := p.MainIntent().StateEvent(roomID, event.StateEncryption, "", &existingEncryption)
+	if err != nil {
+		p.log.Debug().Msgf("Failed to get encryption state of %s: %v", roomID, err)
+	} else {
+		p.Encrypted = existingEncryption.Algorithm == id.AlgorithmMegolmV1
+	}
+
+	p.MXID = roomID
+	p.Unbridged = false
+	p.bridge.portalsLock.Lock()
+	p.bridge.portalsByMXID[p.MXID] = p
+	p.bridge.portalsLock.Unlock()
+	p.Update(nil)
+
+	p.ensureUserInvited(user)
+	go p.addToSpace(user)
+
+	if len(groupInfo.Members) == 0 {
+		if m := user.Client.GetGroupMembers(groupInfo.ID); m != nil {
+			groupInfo.Members = m
+		}
+	}
+	p.UpdateMetadata(user, groupInfo, true)
+	p.UpdateAvatar(user, types.EmptyUID, false)
+
+	p.LastSync = time.Now()
+	p.Update(nil)
+	p.UpdateBridgeInfo()
+
+	return nil
+}
+
+// Unbridge detaches the portal from its room. The portal is kept as a
+// tombstone, so new messages in the group don't create a new room.
+func (p *Portal) Unbridge() {
+	if len(p.MXID) == 0 {
+		return
+	}
+
+	p.removeBridgeInfo()
+	p.Cleanup(true)
+
+	p.bridge.portalsLock.Lock()
+	delete(p.bridge.portalsByMXID, p.MXID)
+	p.bridge.portalsLock.Unlock()
+	p.bridge.DB.Message.DeleteAll(p.Key)
+
+	p.MXID = ""
+	p.Unbridged = true
+	p.Update(nil)
+}
+
 func (p *Portal) addToSpace(user *User) {
 	spaceID := user.GetSpaceRoom()
 	if len(spaceID) == 0 || user.IsInSpace(p.Key) {
@@ -1259,6 +1470,7 @@ func (p *Portal) sendMessage(intent *appservice.IntentAPI, eventType event.Type,
 func (p *Portal) makeMediaBridgeFailureMessage(msgID string, bridgeErr error, converted *ConvertedMessage) *ConvertedMessage {
 	p.log.Error().Msgf("Failed to bridge media for %s: %v", msgID, bridgeErr)
 	converted.Type = event.EventMessage
+	converted.Extra = nil
 	converted.Content = &event.MessageEventContent{
 		MsgType: event.MsgNotice,
 		Body:    fmt.Sprintf("Failed to bridge media: %v", bridgeErr),
@@ -1267,6 +1479,35 @@ func (p *Portal) makeMediaBridgeFailureMessage(msgID string, bridgeErr error, co
 	return converted
 }
 
+// makeOversizeMessage replaces media which is too large for the homeserver
+// with a notice, linking the file drop copy if one is configured.
+func (p *Portal) makeOversizeMessage(msgID string, data *wechat.BlobData, converted *ConvertedMessage) *ConvertedMessage {
+	name := data.Name
+	if len(filepath.Ext(name)) == 0 {
+		name += mimetype.Detect(data.Binary).Extension()
+	}
+	size := FormatSize(int64(len(data.Binary)))
+	p.log.Warn().Msgf("Not uploading %s: %s is %s, over the limit of %s", msgID, name, size, FormatSize(p.bridge.MaxMediaSize()))
+
+	body := fmt.Sprintf("File too large to bridge: %s (%s)", name, size)
+	if p.bridge.FileDropEnabled() {
+		if link, err := p.bridge.UploadFileDrop(name, data.Binary); err != nil {
+			p.log.Warn().Msgf("Failed to upload %s to file drop: %v", msgID, err)
+		} else {
+			body = fmt.Sprintf("%s\n%s", body, link)
+		}
+	}
+
+	converted.Type = event.EventMessage
+	converted.Extra = nil
+	converted.Content = &event.MessageEventContent{
+		MsgType: event.MsgNotice,
+		Body:    body,
+	}
+
+	return converted
+}
+
 func (p *Portal) encryptFileInPlace(data []byte, mimeType string) (string, *event.EncryptedFileInfo) {
 	if !p.Encrypted {
 		return mimeType, nil
@@ -1280,8 +1521,14 @@ func (p *Portal) encryptFileInPlace(data []byte, mimeType string) (string, *even
 	return "application/octet-stream", file
 }
 
-func (p *Portal) uploadMedia(intent *appservice.IntentAPI, data []byte, content *event.MessageEventContent) error {
-	uploadMimeType, file := p.encryptFileInPlace(data, content.Info.MimeType)
+func (p *Portal) uploadBytes(intent *appservice.IntentAPI, data []byte, mimeType string) (id.ContentURI, *event.EncryptedFileInfo, error) {
+	hash := database.MediaHash(data)
+	if cached := p.bridge.DB.Media.Get(hash, p.Encrypted); cached != nil {
+		p.log.Debug().Msgf("Reusing existing upload %s for media %s", cached.MXC, hash)
+		return cached.MXC, cached.File, nil
+	}
+
+	uploadMimeType, file := p.encryptFileInPlace(data, mimeType)
 
 	req := mautrix.ReqUploadMedia{
 		ContentBytes: data,
@@ -1291,28 +1538,74 @@ func (p *Portal) uploadMedia(intent *appservice.IntentAPI, data []byte, content
 	if p.bridge.Config.Homeserver.AsyncMedia {
 		uploaded, err := intent.UploadAsync(req)
 		if err != nil {
-			return err
+			return mxc, nil, err
 		}
 		mxc = uploaded.ContentURI
 	} else {
 		uploaded, err := intent.UploadMedia(req)
 		if err != nil {
-			return err
+			return mxc, nil, err
 		}
 		mxc = uploaded.ContentURI
 	}
 
 	if file != nil {
 		file.URL = mxc.CUString()
+	}
+
+	media := p.bridge.DB.Media.New()
+	media.Hash = hash
+	media.Encrypted = file != nil
+	media.MXC = mxc
+	media.File = file
+	media.Insert()
+
+	return mxc, file, nil
+}
+
+func (p *Portal) uploadMedia(intent *appservice.IntentAPI, data []byte, content *event.MessageEventContent) error {
+	// Metadata has to be collected before the data is encrypted in place.
+	info := media.Probe(data, content.Info.MimeType)
+	if content.Info.Width == 0 && content.Info.Height == 0 {
+		content.Info.Width, content.Info.Height = info.Width, info.Height
+	}
+	if content.Info.Duration == 0 {
+		content.Info.Duration = int(info.Duration.Milliseconds())
+	}
+	thumbnail, err := media.MakeThumbnail(data, content.Info.MimeType)
+	if err != nil {
+		p.log.Debug().Msgf("Failed to create thumbnail: %v", err)
+	}
+	content.Info.Size = len(data)
+
+	mxc, file, err := p.uploadBytes(intent, data, content.Info.MimeType)
+	if err != nil {
+		return err
+	}
+	if file != nil {
 		content.File = file
 	} else {
 		content.URL = mxc.CUString()
 	}
 
-	content.Info.Size = len(data)
-	if content.Info.Width == 0 && content.Info.Height == 0 && strings.HasPrefix(content.Info.MimeType, "image/") {
-		cfg, _, _ := image.DecodeConfig(bytes.NewReader(data))
-		content.Info.Width, content.Info.Height = cfg.Width, cfg.Height
+	if thumbnail != nil {
+		thumbnailSize := len(thumbnail.Data)
+		thumbnailMXC, thumbnailFile, err := p.uploadBytes(intent, thumbnail.Data, media.ThumbnailMimeType)
+		if err != nil {
+			p.log.Warn().Msgf("Failed to upload thumbnail: %v", err)
+		} else {
+			if thumbnailFile != nil {
+				content.Info.ThumbnailFile = thumbnailFile
+			} else {
+				content.Info.ThumbnailURL = thumbnailMXC.CUString()
+			}
+			content.Info.ThumbnailInfo = &event.FileInfo{
+				MimeType: media.ThumbnailMimeType,
+				Width:    thumbnail.Width,
+				Height:   thumbnail.Height,
+				Size:     thumbnailSize,
+			}
+		}
 	}
 
 	return nil
@@ -1349,9 +1642,17 @@ func (p *Portal) preprocessMatrixMedia(content *event.MessageEventContent) (stri
 }
 
 func (p *Portal) HandleMatrixMessage(sender *User, evt *event.Event) {
-	if err := p.canBridgeFrom(sender); err != nil {
+	realSender := sender
+	if err := p.canBridgeFrom(sender); err == errUserNotLoggedIn && p.HasRelaybot() {
+		sender = p.GetRelayUser()
+		if sender == nil || p.canBridgeFrom(sender) != nil {
+			p.log.Debug().Msgf("Not bridging %s from %s: relay user is not available", evt.ID, realSender.MXID)
+			return
+		}
+	} else if err != nil {
 		return
 	}
+	isRelay := sender != realSender
 
 	content, ok := evt.Content.Parsed.(*event.MessageEventContent)
 	if !ok {
@@ -1373,7 +1674,8 @@ func (p *Portal) HandleMatrixMessage(sender *User, evt *event.Event) {
 		}
 	}
 
-	if evt.Type == event.EventSticker {
+	isSticker := evt.Type == event.EventSticker
+	if isSticker {
 		content.MsgType = event.MsgImage
 	}
 
@@ -1383,30 +1685,63 @@ func (p *Portal) HandleMatrixMessage(sender *User, evt *event.Event) {
 		From:      wechat.User{ID: sender.User.UID.Uin},
 		Chat:      wechat.Chat{ID: target},
 	}
+	var audioName string
+	var audioData []byte
 
 	switch content.MsgType {
 	case event.MsgText, event.MsgEmote:
 		var mentions []string
 		msg.Type = wechat.EventText
-		text := content.Body
+		// Emoji are only converted in the text written by the sender, so names
+		// added for mentions, replies and relaying still match on WeChat.
+		outboundEmoticons := p.bridge.Config.Bridge.Emoticons.Outbound
+		text := p.bridge.Emoticons.ToCodes(content.Body, outboundEmoticons)
+
+		mentionLog := p.log.With().
+			Str("room_id", p.MXID.String()).
+			Str("event_id", evt.ID.String()).
+			Logger()
 
 		if content.Format == event.FormatHTML {
-			formatted, mentionedUIDs := p.bridge.Formatter.ParseMatrix(content.FormattedBody, content.Mentions)
+			formatted, mentionedUIDs := p.bridge.Formatter.ParseMatrix(content.FormattedBody, content.Mentions, p.formattingMode(), mentionLog)
+			formatted = p.bridge.Emoticons.ToCodes(formatted, outboundEmoticons)
+			usedNames := make(map[string]string, len(mentionedUIDs))
 			for _, mention := range mentionedUIDs {
-				groupNickname := sender.Client.GetGroupMemberNickname(p.Key.UID.Uin, mention)
-				if len(groupNickname) > 0 {
-					formatted = strings.ReplaceAll(formatted, "@"+mention, "@"+groupNickname)
-				} else {
-					puppet := p.bridge.GetPuppetByUID(types.NewUserUID(mention))
-					if puppet != nil {
-						formatted = strings.ReplaceAll(formatted, "@"+mention, "@"+puppet.Displayname)
+				name, nameSource := sender.Client.GetGroupMemberNickname(p.Key.UID.Uin, mention), "group_nickname"
+				if len(name) == 0 {
+					nameSource = "uid"
+					if puppet := p.bridge.GetPuppetByUID(types.NewUserUID(mention)); puppet != nil && len(puppet.Displayname) > 0 {
+						name, nameSource = puppet.Displayname, "displayname"
 					}
 				}
+				if len(name) > 0 {
+					formatted = strings.ReplaceAll(formatted, "@"+mention, "@"+name)
+				}
+
+				traceEvt := mentionLog.Debug().Str("uid", mention).Str("name", name).Str("name_source", nameSource)
+				if other, ok := usedNames[name]; ok && len(name) > 0 {
+					traceEvt = traceEvt.Str("name_collides_with", other)
+				}
+				traceEvt.Msg("Resolved name of mentioned user")
+				usedNames[name] = mention
 			}
 			mentions = append(mentions, mentionedUIDs...)
 			text = formatted
 		}
 
+		if p.IsGroupChat() && isRoomMention(content) {
+			if p.canMentionRoom(realSender) {
+				mentions = append([]string{wechat.MentionAll}, mentions...)
+				if loc := roomMentionRegex.FindStringSubmatchIndex(text); loc != nil {
+					text = text[:loc[3]] + "@" + wechatMentionAllText + text[loc[1]:]
+				} else {
+					text = fmt.Sprintf("@%s %s", wechatMentionAllText, text)
+				}
+			} else {
+				p.log.Debug().Msgf("Not notifying everyone for %s: %s doesn't have the power level", evt.ID, realSender.MXID)
+			}
+		}
+
 		if len(replyMention) > 0 {
 			if info := sender.Client.GetUserInfo(replyMention); info != nil {
 				mentions = append([]string{replyMention}, mentions...)
@@ -1414,47 +1749,102 @@ func (p *Portal) HandleMatrixMessage(sender *User, evt *event.Event) {
 			}
 		}
 
-		if content.MsgType == event.MsgEmote {
+		if isRelay {
+			text = p.formatRelayMessage(realSender, content, text)
+		} else if content.MsgType == event.MsgEmote {
 			text = "/me " + text
 		}
 
 		msg.Content = text
 		if len(mentions) > 0 {
 			msg.Mentions = mentions
+			mentionLog.Debug().Strs("wechat_mentions", mentions).Msg("Sending mentions to WeChat")
 		}
 	case event.MsgImage, event.MsgAudio, event.MsgVideo, event.MsgFile:
+		limit := p.bridge.Config.Bridge.Media.WechatMaxSize
+		if limit > 0 && int64(content.GetInfo().Size) > limit {
+			notice := fmt.Sprintf("File too large for WeChat: %s is over the limit of %s", FormatSize(int64(content.GetInfo().Size)), FormatSize(limit))
+			p.log.Warn().Msg(notice)
+			p.replyFailure(sender, evt, notice)
+			return
+		}
 		name, data, err := p.preprocessMatrixMedia(content)
 		if data == nil {
 			notice := fmt.Sprintf("Failed to process matrix media: %v", err)
 			p.log.Warn().Msg(notice)
 			p.replyFailure(sender, evt, notice)
 			return
+		} else if limit > 0 && int64(len(data)) > limit {
+			notice := fmt.Sprintf("File too large for WeChat: %s is over the limit of %s", FormatSize(int64(len(data))), FormatSize(limit))
+			p.log.Warn().Msg(notice)
+			p.replyFailure(sender, evt, notice)
+			return
 		}
 		msg.Type = wechat.ToEventType(content.MsgType)
 		blob := &wechat.BlobData{
 			Name:   name,
 			Binary: data,
 		}
-		if content.MsgType == event.MsgImage {
-			msg.Data = []*wechat.BlobData{blob}
-		} else if content.MsgType == event.MsgAudio {
-			if binary, err := ogg2mp3(data); err != nil {
-				notice := fmt.Sprintf("Failed to convert audio to mp3: %v", err)
+		if isSticker {
+			mimeType := content.GetInfo().MimeType
+			if len(mimeType) == 0 {
+				mimeType = mimetype.Detect(data).String()
+			}
+			sticker, mimeType, err := media.ToWechatSticker(data, mimeType)
+			if errors.Is(err, media.ErrNoLottieConverter) {
+				p.replyFailure(sender, evt, "Lottie stickers can't be converted because lottieconverter is not installed, sending the sticker as a file")
+				msg.Type = wechat.EventFile
+				msg.Data = blob
+			} else if err != nil {
+				notice := fmt.Sprintf("Failed to convert sticker: %v", err)
 				p.log.Warn().Msg(notice)
 				p.replyFailure(sender, evt, notice)
 				return
 			} else {
-				randBytes := make([]byte, 4)
-				rand.Read(randBytes)
-				msg.Type = wechat.EventFile
+				msg.Type = wechat.EventSticker
+				if detected := mimetype.Lookup(mimeType); detected != nil {
+					name = strings.TrimSuffix(name, filepath.Ext(name)) + detected.Extension()
+				}
+				msg.Data = &wechat.BlobData{
+					Name:   name,
+					Mime:   mimeType,
+					Binary: sticker,
+				}
+			}
+		} else if content.MsgType == event.MsgImage {
+			msg.Data = []*wechat.BlobData{blob}
+		} else if content.MsgType == event.MsgAudio {
+			audioName, audioData = name, data
+			if voice, duration, err := p.bridge.Audio.MatrixToSilk(context.Background(), data); err != nil {
+				p.log.Warn().Msgf("Failed to convert audio to silk, sending as file instead: %v", err)
+				if err := p.convertVoiceFallback(msg, name, data); err != nil {
+					notice := err.Error()
+					p.log.Warn().Msg(notice)
+					p.replyFailure(sender, evt, notice)
+					return
+				}
+			} else {
 				msg.Data = &wechat.BlobData{
-					Name:   fmt.Sprintf("VOICE_%s.mp3", hex.EncodeToString(randBytes)),
-					Binary: binary,
+					Name:     fmt.Sprintf("VOICE_%s.silk", randomHex(4)),
+					Mime:     "audio/silk",
+					Duration: duration,
+					Binary:   voice,
 				}
 			}
 		} else {
 			msg.Data = blob
 		}
+	case event.MsgLocation:
+		location, err := convertMatrixLocation(content, evt.Content.Raw)
+		if err != nil {
+			notice := fmt.Sprintf("Failed to parse location: %v", err)
+			p.log.Warn().Msg(notice)
+			p.replyFailure(sender, evt, notice)
+			return
+		}
+		location.Latitude, location.Longitude = p.bridge.toGCJ02(location.Latitude, location.Longitude)
+		msg.Type = wechat.EventLocation
+		msg.Data = location
 	default:
 		notice := fmt.Sprintf("%s not support", content.MsgType)
 		p.log.Warn().Msg(notice)
@@ -1464,14 +1854,117 @@ func (p *Portal) HandleMatrixMessage(sender *User, evt *event.Event) {
 
 	msgID := "FAKE::" + strconv.FormatInt(evt.Timestamp, 10)
 	p.log.Debug().Msgf("Sending event %s to WeChat", evt.ID)
-	if _, err := sender.Client.SendEvent(msg); err != nil {
+	_, err := sender.Client.SendEvent(msg)
+	if err != nil && msg.Type == wechat.EventAudio {
+		p.log.Warn().Msgf("Failed to send %s as voice, retrying as file: %v", evt.ID, err)
+		if err = p.convertVoiceFallback(msg, audioName, audioData); err == nil {
+			_, err = sender.Client.SendEvent(msg)
+		}
+	}
+	if err != nil {
 		p.replyFailure(sender, evt, err.Error())
 	} else {
+		if isRelay && msg.Type != wechat.EventText {
+			if err = p.sendRelayCaption(sender, realSender, evt, content); err != nil {
+				p.log.Warn().Msgf("Failed to send relay caption of %s: %v", evt.ID, err)
+			}
+		}
 		// TODO: get msgID from WeChat
 		p.finishHandling(nil, msgID, time.UnixMilli(evt.Timestamp), sender.UID, evt.ID, database.MsgNormal, database.MsgNoError)
 	}
 }
 
+func isRoomMention(content *event.MessageEventContent) bool {
+	if content.Mentions != nil {
+		return content.Mentions.Room
+	}
+
+	return roomMentionRegex.MatchString(content.Body)
+}
+
+// canMentionRoom checks the notification power level, like homeservers do
+// for @room notifications.
+func (p *Portal) canMentionRoom(user *User) bool {
+	levels, err := p.MainIntent().PowerLevels(p.MXID)
+	if err != nil {
+		p.log.Warn().Msgf("Failed to get power levels: %v", err)
+		return false
+	}
+
+	return levels.GetUserLevel(user.MXID) >= levels.Notifications.Room()
+}
+
+// convertVoiceFallback turns the voice message into a mp3 file for agents which can't send voice.
+// Without ffmpeg, the original audio file is sent instead.
+func (p *Portal) convertVoiceFallback(msg *wechat.Event, name string, data []byte) error {
+	binary, err := p.bridge.Audio.ToMP3(context.Background(), data)
+	if errors.Is(err, audio.ErrNoBackend) {
+		msg.Type = wechat.EventFile
+		msg.Data = &wechat.BlobData{
+			Name:   name,
+			Binary: data,
+		}
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to convert audio to mp3: %w", err)
+	}
+
+	msg.Type = wechat.EventFile
+	msg.Data = &wechat.BlobData{
+		Name:   fmt.Sprintf("VOICE_%s.mp3", randomHex(4)),
+		Binary: binary,
+	}
+
+	return nil
+}
+
+func randomHex(n int) string {
+	randBytes := make([]byte, n)
+	_, _ = rand.Read(randBytes)
+
+	return hex.EncodeToString(randBytes)
+}
+
+func (p *Portal) formatRelayMessage(sender *User, content *event.MessageEventContent, text string) string {
+	relay := &p.bridge.Config.Bridge.Relay
+	if !relay.HasFormat(content.MsgType) {
+		return text
+	}
+
+	member := p.MainIntent().Member(p.MXID, sender.MXID)
+	if member == nil {
+		member = &event.MemberEventContent{}
+	}
+
+	formatted, err := relay.FormatMessage(content, text, sender.MXID, *member)
+	if err != nil {
+		p.log.Warn().Msgf("Failed to apply relay format for %s: %v", content.MsgType, err)
+		return text
+	}
+
+	return formatted
+}
+
+// Media can't carry a caption on WeChat, so the relay prefix is sent as a
+// separate text message after the media was delivered.
+func (p *Portal) sendRelayCaption(relay, sender *User, evt *event.Event, content *event.MessageEventContent) error {
+	caption := p.formatRelayMessage(sender, content, "")
+	if len(caption) == 0 {
+		return nil
+	}
+
+	_, err := relay.Client.SendEvent(&wechat.Event{
+		ID:        string(evt.ID) + ":caption",
+		Timestamp: evt.Timestamp,
+		From:      wechat.User{ID: relay.UID.Uin},
+		Chat:      wechat.Chat{ID: p.Key.UID.Uin},
+		Type:      wechat.EventText,
+		Content:   caption,
+	})
+
+	return err
+}
+
 func (p *Portal) HandleMatrixRedaction(sender *User, evt *event.Event) {
 	// TODO:
 }
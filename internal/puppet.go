@@ -144,16 +144,24 @@ func (p *Puppet) updatePortalName() {
 	})
 }
 
-func (p *Puppet) SyncContact(source *User, forceAvatarSync bool, reason string) {
+// SyncContact fetches the contact info through the source user and syncs the
+// puppet with it. Like Sync, it reports whether the remark changed.
+func (p *Puppet) SyncContact(source *User, forceAvatarSync bool, reason string) (*types.ContactInfo, bool) {
 	info := source.Client.GetUserInfo(p.UID.Uin)
-	if info != nil {
-		p.Sync(source, types.NewContact(info.ID, info.Name, info.Remark), forceAvatarSync, false)
-	} else {
+	if info == nil {
 		p.log.Warn().Msgf("No contact info found through %s in SyncContact (sync reason: %s)", source.MXID, reason)
+		return nil, false
 	}
+
+	contact := types.NewContact(info.ID, info.Name, info.Remark)
+
+	return contact, p.Sync(source, contact, forceAvatarSync, false)
 }
 
-func (p *Puppet) Sync(source *User, contact *types.ContactInfo, forceAvatarSync, forcePortalSync bool) {
+// Sync updates the puppet with the contact info seen by the source user. It
+// reports whether the remark of the source user changed, the caller then has
+// to update the room displaynames with updateRemarkDisplaynames.
+func (p *Puppet) Sync(source *User, contact *types.ContactInfo, forceAvatarSync, forcePortalSync bool) bool {
 	p.syncLock.Lock()
 	defer p.syncLock.Unlock()
 
@@ -164,9 +172,12 @@ func (p *Puppet) Sync(source *User, contact *types.ContactInfo, forceAvatarSync,
 
 	p.log.Debug().Msgf("Syncing info through %s", source.UID)
 
-	update := false
+	update, remarkChanged := false, false
 	if contact != nil {
-		update = p.UpdateName(*contact, forcePortalSync) || update
+		// The remark is private to the source user, so it's kept out of the
+		// global name and only used for room displaynames in their portals.
+		update = p.UpdateName(*types.NewContact(contact.Uin, contact.Name, ""), forcePortalSync) || update
+		remarkChanged = source.SetContactRemark(p.UID.Uin, contact.Remark)
 	}
 	if len(p.Avatar) == 0 || forceAvatarSync || p.bridge.Config.Bridge.UserAvatarSync {
 		update = p.UpdateAvatar(source, forceAvatarSync, forcePortalSync) || update
@@ -175,6 +186,8 @@ func (p *Puppet) Sync(source *User, contact *types.ContactInfo, forceAvatarSync,
 		p.LastSync = time.Now()
 		p.Update()
 	}
+
+	return remarkChanged
 }
 
 func (br *WechatBridge) ParsePuppetMXID(mxid id.UserID) (uid types.UID, ok bool) {
@@ -325,17 +338,27 @@ func (br *WechatBridge) NewPuppet(dbPuppet *database.Puppet) *Puppet {
 	}
 }
 
-func reuploadAvatar(intent *appservice.IntentAPI, url string) (id.ContentURI, error) {
+func (br *WechatBridge) reuploadAvatar(intent *appservice.IntentAPI, url string) (id.ContentURI, error) {
 	data, err := GetBytes(url)
 	if err != nil {
 		return id.ContentURI{}, fmt.Errorf("failed to download avatar: %w", err)
 	}
 
+	hash := database.MediaHash(data)
+	if cached := br.DB.Media.Get(hash, false); cached != nil {
+		return cached.MXC, nil
+	}
+
 	mime := http.DetectContentType(data)
 	resp, err := intent.UploadBytes(data, mime)
 	if err != nil {
 		return id.ContentURI{}, fmt.Errorf("failed to upload avatar to Matrix: %w", err)
 	}
 
+	media := br.DB.Media.New()
+	media.Hash = hash
+	media.MXC = resp.ContentURI
+	media.Insert()
+
 	return resp.ContentURI, nil
 }
@@ -3,6 +3,7 @@ package internal
 import (
 	"errors"
 	"fmt"
+	"html"
 	"math/rand"
 	"net/http"
 	"runtime/debug"
@@ -174,15 +175,20 @@ func (u *User) doPuppetResync() {
 			u.log.Warn().Msgf("Failed to get group info for %s to do background sync", portal.Key.UID)
 		}
 	}
+	changedRemarks := make(map[*Puppet]*types.ContactInfo)
 	for _, puppet := range puppets {
 		u.log.Debug().Msgf("Doing background sync for user: %v", puppet.UID)
 		info := u.Client.GetUserInfo(puppet.UID.Uin)
 		if info != nil {
-			puppet.Sync(u, types.NewContact(info.ID, info.Name, info.Remark), true, true)
+			contact := types.NewContact(info.ID, info.Name, info.Remark)
+			if puppet.Sync(u, contact, true, true) {
+				changedRemarks[puppet] = contact
+			}
 		} else {
 			u.log.Warn().Msgf("Failed to get contact info for %s in background sync", puppet.UID)
 		}
 	}
+	u.updateRemarkDisplaynames(changedRemarks)
 }
 
 func (u *User) ensureInvited(intent *appservice.IntentAPI, roomID id.RoomID, isDirect bool) (ok bool) {
@@ -346,10 +352,19 @@ func (u *User) MarkLogin() {
 	if info != nil {
 		u.UID = types.NewUserUID(info.ID)
 		u.addToUIDMap()
+		// MarkLogin runs again on every reconnect of the agent, recent chats
+		// are only synced after the first login.
+		syncRecentChats := !u.RecentChatsSynced && u.bridge.Config.Bridge.InitialChatSync.Count > 0
+		if syncRecentChats {
+			u.RecentChatsSynced = true
+		}
 		u.Update()
 
 		go u.BridgeState.Send(status.BridgeState{StateEvent: status.StateConnected})
 		go u.tryAutomaticDoublePuppeting()
+		if syncRecentChats {
+			go u.createRecentPortals()
+		}
 
 		u.log.Debug().Msgf("Login to wechat %s", u.UID)
 	} else {
@@ -357,6 +372,66 @@ func (u *User) MarkLogin() {
 	}
 }
 
+// createRecentPortals creates portals for the most recent chats after login.
+// Portals are created one at a time to stay below homeserver rate limits.
+func (u *User) createRecentPortals() {
+	syncConfig := u.bridge.Config.Bridge.InitialChatSync
+	chats := u.Client.GetRecentChats(syncConfig.Count)
+	if len(chats) > syncConfig.Count {
+		chats = chats[:syncConfig.Count]
+	}
+	u.log.Info().Msgf("Creating portals for %d recent chats", len(chats))
+
+	created := 0
+	for _, chat := range chats {
+		var uid types.UID
+		if chat.Type == wechat.ChatGroup || strings.HasSuffix(chat.ID, "@chatroom") {
+			uid = types.NewGroupUID(chat.ID)
+		} else {
+			uid = types.NewUserUID(chat.ID)
+		}
+
+		portal := u.GetPortalByUID(uid)
+		if len(portal.MXID) > 0 {
+			portal.addToSpace(u)
+			continue
+		} else if portal.Unbridged {
+			continue
+		}
+
+		if created > 0 && syncConfig.Delay > 0 {
+			time.Sleep(syncConfig.Delay)
+		}
+		created++
+
+		var err error
+		if uid.IsUser() {
+			portal, _, _, err = u.StartPM(uid, "recent chat after login")
+		} else {
+			err = portal.CreateMatrixRoom(u, nil, false)
+		}
+		if err != nil {
+			u.log.Warn().Msgf("Failed to create portal for recent chat %s: %v", uid, err)
+			continue
+		}
+
+		if syncConfig.Backfill > 0 {
+			u.backfillPortal(portal, chat.ID, syncConfig.Backfill)
+		}
+	}
+	u.log.Info().Msgf("Created %d portals for recent chats", created)
+}
+
+// backfillPortal bridges the latest messages of a newly created portal through
+// the regular message handling, which skips anything already bridged.
+func (u *User) backfillPortal(portal *Portal, chatID string, limit int) {
+	history := u.Client.GetChatHistory(chatID, limit)
+	u.log.Debug().Msgf("Backfilling %d messages into %s", len(history), portal.Key)
+	for _, e := range history {
+		portal.messages <- PortalMessage{event: e, source: u}
+	}
+}
+
 func (u *User) DeleteConnection() {
 	u.connLock.Lock()
 	defer u.connLock.Unlock()
@@ -440,25 +515,79 @@ func (u *User) GetPortalByUID(uid types.UID) *Portal {
 }
 
 func (u *User) ResyncContacts(forceAvatarSync bool) error {
-	for _, contact := range u.Client.GetFriendList() {
-		uid := types.NewUserUID(contact.ID)
+	changedRemarks := make(map[*Puppet]*types.ContactInfo)
+	for _, friend := range u.Client.GetFriendList() {
+		uid := types.NewUserUID(friend.ID)
 		puppet := u.bridge.GetPuppetByUID(uid)
 		if puppet != nil {
-			puppet.Sync(u, types.NewContact(contact.ID, contact.Name, contact.Remark), forceAvatarSync, true)
+			contact := types.NewContact(friend.ID, friend.Name, friend.Remark)
+			if puppet.Sync(u, contact, forceAvatarSync, true) {
+				changedRemarks[puppet] = contact
+			}
 		} else {
 			u.log.Warn().Msgf("Got a nil puppet for %s while syncing contacts", uid)
 		}
 	}
+	u.updateRemarkDisplaynames(changedRemarks)
 
 	return nil
 }
 
+// remarkChanges collects the contacts whose remark changed while syncing
+// group members in parallel.
+type remarkChanges struct {
+	contacts map[*Puppet]*types.ContactInfo
+	lock     sync.Mutex
+}
+
+func (rc *remarkChanges) add(puppet *Puppet, contact *types.ContactInfo) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+
+	if rc.contacts == nil {
+		rc.contacts = make(map[*Puppet]*types.ContactInfo)
+	}
+	rc.contacts[puppet] = contact
+}
+
+// updateRemarkDisplaynames applies changed remarks to the room displaynames
+// of the contacts in this user's portals. All contacts are handled in a
+// single pass over the group portals.
+func (u *User) updateRemarkDisplaynames(contacts map[*Puppet]*types.ContactInfo) {
+	if len(contacts) == 0 {
+		return
+	}
+
+	for puppet, contact := range contacts {
+		if portal := u.GetPortalByUID(puppet.UID); len(portal.MXID) > 0 {
+			portal.UpdateRoomNickname(puppet, contact, "")
+		}
+	}
+
+	for _, portal := range u.bridge.dbPortalsToPortals(u.bridge.DB.Portal.FindGroupChats(u.UID)) {
+		if portal == nil {
+			continue
+		}
+		for puppet, contact := range contacts {
+			if !u.bridge.AS.StateStore.IsInRoom(portal.MXID, puppet.MXID) {
+				continue
+			}
+			var groupNickname string
+			if len(contact.Remark) == 0 && u.Client != nil {
+				// The group nickname is needed again once the remark is gone.
+				groupNickname = u.Client.GetGroupMemberNickname(portal.Key.UID.Uin, puppet.UID.Uin)
+			}
+			portal.UpdateRoomNickname(puppet, contact, groupNickname)
+		}
+	}
+}
+
 func (u *User) ResyncGroups(createPortals bool) error {
 	for _, group := range u.Client.GetGroupList() {
 		uid := types.NewGroupUID(group.ID)
 		portal := u.GetPortalByUID(uid)
 		if len(portal.MXID) == 0 {
-			if createPortals {
+			if createPortals && !portal.Unbridged {
 				if err := portal.CreateMatrixRoom(u, group, true); err != nil {
 					return fmt.Errorf("failed to create room for %s: %v", uid, err)
 				}
@@ -474,7 +603,9 @@ func (u *User) ResyncGroups(createPortals bool) error {
 func (u *User) StartPM(uid types.UID, reason string) (*Portal, *Puppet, bool, error) {
 	u.log.Debug().Msgf("Starting PM with %s from %s", uid, reason)
 	puppet := u.bridge.GetPuppetByUID(uid)
-	puppet.SyncContact(u, true, reason)
+	if contact, remarkChanged := puppet.SyncContact(u, true, reason); remarkChanged {
+		defer u.updateRemarkDisplaynames(map[*Puppet]*types.ContactInfo{puppet: contact})
+	}
 	portal := u.GetPortalByUID(puppet.UID)
 	if len(portal.MXID) > 0 {
 		ok := portal.ensureUserInvited(u)
@@ -506,7 +637,7 @@ func (u *User) updateAvatar(uid types.UID, avatarID *string, avatarURL *id.Conte
 		return false
 	}
 
-	resp, err := reuploadAvatar(intent, url)
+	resp, err := u.bridge.reuploadAvatar(intent, url)
 	if err != nil {
 		u.log.Warn().Msgf("Failed to reupload avatar: %v", err)
 		return false
@@ -520,6 +651,15 @@ func (u *User) updateAvatar(uid types.UID, avatarID *string, avatarURL *id.Conte
 }
 
 func (u *User) processEvent(e *wechat.Event) {
+	switch e.Type {
+	case wechat.EventFriendRequest:
+		u.handleFriendRequest(e)
+		return
+	case wechat.EventGroupInvite:
+		u.handleGroupInvite(e)
+		return
+	}
+
 	if strings.HasSuffix(e.Chat.ID, "@chatroom") { // Group
 		uid := types.NewGroupUID(e.Chat.ID)
 		portal := u.bridge.GetPortalByUID(database.NewPortalKey(uid, u.UID))
@@ -536,6 +676,95 @@ func (u *User) processEvent(e *wechat.Event) {
 	}
 }
 
+func (u *User) handleFriendRequest(e *wechat.Event) {
+	request, ok := e.Data.(*wechat.FriendRequestData)
+	if !ok || request == nil || len(request.ID) == 0 {
+		u.log.Warn().Msgf("Got friend request %s without request data", e.ID)
+		return
+	}
+
+	u.SavePendingRequest(database.RequestFriend, request.ID, request)
+
+	name := firstNonEmpty(request.Nickname, request.UserID, request.ID)
+	body := fmt.Sprintf("%s wants to add you as a contact", name)
+	formatted := fmt.Sprintf("<strong>%s</strong> wants to add you as a contact", html.EscapeString(name))
+	formatted = u.prependAvatar(request.Avatar, formatted)
+	if len(request.Message) > 0 {
+		body += ":\n> " + strings.ReplaceAll(request.Message, "\n", "\n> ")
+		formatted += fmt.Sprintf(":<blockquote>%s</blockquote>", strings.ReplaceAll(html.EscapeString(request.Message), "\n", "<br>"))
+	}
+	body += fmt.Sprintf("\n\nUse `accept %[1]s` or `reject %[1]s` to respond.", request.ID)
+	formatted += fmt.Sprintf("<p>Use <code>accept %[1]s</code> or <code>reject %[1]s</code> to respond.</p>", html.EscapeString(request.ID))
+
+	u.sendManagementNotice(body, formatted)
+}
+
+func (u *User) handleGroupInvite(e *wechat.Event) {
+	invite, ok := e.Data.(*wechat.GroupInviteData)
+	if !ok || invite == nil || len(invite.ID) == 0 {
+		u.log.Warn().Msgf("Got group invite %s without invite data", e.ID)
+		return
+	}
+
+	u.SavePendingRequest(database.RequestGroupInvite, invite.ID, invite)
+
+	inviter := firstNonEmpty(invite.InviterName, invite.Inviter, "Someone")
+	group := firstNonEmpty(invite.GroupName, invite.GroupID, "a group")
+	body := fmt.Sprintf("%s invited you to %s", inviter, group)
+	formatted := fmt.Sprintf("%s invited you to <strong>%s</strong>", html.EscapeString(inviter), html.EscapeString(group))
+	formatted = u.prependAvatar(invite.Avatar, formatted)
+	body += fmt.Sprintf("\n\nUse `join %s` to join the group.", invite.ID)
+	formatted += fmt.Sprintf("<p>Use <code>join %s</code> to join the group.</p>", html.EscapeString(invite.ID))
+
+	u.sendManagementNotice(body, formatted)
+}
+
+// prependAvatar puts a small avatar image in front of a management room notice.
+func (u *User) prependAvatar(url, formatted string) string {
+	if len(url) == 0 {
+		return formatted
+	}
+
+	avatarURL, err := u.bridge.reuploadAvatar(u.bridge.Bot, url)
+	if err != nil {
+		u.log.Warn().Msgf("Failed to reupload avatar for management room notice: %v", err)
+		return formatted
+	}
+
+	return fmt.Sprintf(`<img src="%s" alt="" width="32" height="32"> %s`, avatarURL, formatted)
+}
+
+func (u *User) sendManagementNotice(body, formatted string) {
+	content := &event.MessageEventContent{
+		MsgType:       event.MsgNotice,
+		Body:          body,
+		Format:        event.FormatHTML,
+		FormattedBody: formatted,
+	}
+	if _, err := u.bridge.Bot.SendMessageEvent(u.GetManagementRoom(), event.EventMessage, content); err != nil {
+		u.log.Warn().Msgf("Failed to send notice to management room: %v", err)
+	}
+}
+
+// popFriendRequest returns and forgets a pending friend request.
+func (u *User) popFriendRequest(id string) *wechat.FriendRequestData {
+	request := &wechat.FriendRequestData{}
+	if !u.PopPendingRequest(database.RequestFriend, id, request) {
+		return nil
+	}
+
+	return request
+}
+
+func (u *User) popGroupInvite(id string) *wechat.GroupInviteData {
+	invite := &wechat.GroupInviteData{}
+	if !u.PopPendingRequest(database.RequestGroupInvite, id, invite) {
+		return nil
+	}
+
+	return invite
+}
+
 // ChildOverride
 func (br *WechatBridge) GetIUser(userID id.UserID, create bool) bridge.User {
 	return br.getUserByMXID(userID, false)
@@ -0,0 +1,161 @@
+package wechat
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type AppMsgType int
+
+const (
+	AppMsgText        AppMsgType = 1
+	AppMsgMusic       AppMsgType = 3
+	AppMsgVideo       AppMsgType = 4
+	AppMsgLink        AppMsgType = 5
+	AppMsgFile        AppMsgType = 6
+	AppMsgChatHistory AppMsgType = 19
+	AppMsgMiniProgram AppMsgType = 33
+	AppMsgMiniApp     AppMsgType = 36
+	AppMsgChannels    AppMsgType = 51
+	AppMsgQuote       AppMsgType = 57
+	AppMsgMusicCard   AppMsgType = 76
+	AppMsgTransfer    AppMsgType = 2000
+	AppMsgRedPacket   AppMsgType = 2001
+)
+
+// Transfer states from wcpayinfo.paysubtype.
+const (
+	TransferSent     = 1
+	TransferReceived = 3
+	TransferRefunded = 4
+)
+
+// AppMsg is the <appmsg> element of WeChat rich messages.
+type AppMsg struct {
+	Type        AppMsgType `xml:"type"`
+	Title       string     `xml:"title"`
+	Description string     `xml:"des"`
+	URL         string     `xml:"url"`
+	ThumbURL    string     `xml:"thumburl"`
+	DataURL     string     `xml:"dataurl"`
+	SourceName  string     `xml:"sourcedisplayname"`
+
+	Attach struct {
+		TotalLen int64  `xml:"totallen"`
+		FileExt  string `xml:"fileext"`
+	} `xml:"appattach"`
+
+	WeApp struct {
+		Username string `xml:"username"`
+		AppID    string `xml:"appid"`
+		PagePath string `xml:"pagepath"`
+		IconURL  string `xml:"weappiconurl"`
+	} `xml:"weappinfo"`
+
+	PayInfo struct {
+		SubType      int    `xml:"paysubtype"`
+		FeeDesc      string `xml:"feedesc"`
+		Memo         string `xml:"pay_memo"`
+		SceneText    string `xml:"scenetext"`
+		ReceiverDesc string `xml:"receivertitle"`
+		SenderDesc   string `xml:"sendertitle"`
+	} `xml:"wcpayinfo"`
+
+	RecordItem string `xml:"recorditem"`
+}
+
+type appMsgEnvelope struct {
+	AppMsg  AppMsg `xml:"appmsg"`
+	AppInfo struct {
+		AppName string `xml:"appname"`
+	} `xml:"appinfo"`
+}
+
+// ParseAppMsg parses the raw XML of an app message. The XML is either a full
+// <msg> envelope or a bare <appmsg> element.
+func ParseAppMsg(raw string) (*AppMsg, error) {
+	raw = strings.TrimSpace(raw)
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("empty app message")
+	}
+
+	var envelope appMsgEnvelope
+	if err := newXMLDecoder(raw).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("failed to parse app message: %w", err)
+	}
+	msg := &envelope.AppMsg
+	if msg.Type == 0 && len(msg.Title) == 0 {
+		// Not wrapped in <msg>, the root element is <appmsg> itself.
+		if err := newXMLDecoder(raw).Decode(msg); err != nil {
+			return nil, fmt.Errorf("failed to parse app message: %w", err)
+		}
+	}
+	if len(msg.SourceName) == 0 {
+		msg.SourceName = envelope.AppInfo.AppName
+	}
+
+	return msg, nil
+}
+
+func newXMLDecoder(raw string) *xml.Decoder {
+	decoder := xml.NewDecoder(strings.NewReader(raw))
+	decoder.Strict = false
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	return decoder
+}
+
+type RecordDataType int
+
+const (
+	RecordText        RecordDataType = 1
+	RecordImage       RecordDataType = 2
+	RecordVoice       RecordDataType = 3
+	RecordVideo       RecordDataType = 4
+	RecordLink        RecordDataType = 5
+	RecordLocation    RecordDataType = 6
+	RecordFile        RecordDataType = 8
+	RecordChatHistory RecordDataType = 17
+)
+
+// RecordInfo is the content of a forwarded chat history bundle.
+type RecordInfo struct {
+	Title       string       `xml:"title"`
+	Description string       `xml:"desc"`
+	Items       []RecordItem `xml:"datalist>dataitem"`
+}
+
+// RecordItem is a single message of a chat history bundle. Media is sent by
+// the agent as app message blob keyed by DataID.
+type RecordItem struct {
+	Type       RecordDataType `xml:"datatype,attr"`
+	DataID     string         `xml:"dataid,attr"`
+	Content    string         `xml:"datadesc"`
+	Title      string         `xml:"datatitle"`
+	SourceName string         `xml:"sourcename"`
+	SourceTime string         `xml:"sourcetime"`
+	Format     string         `xml:"datafmt"`
+	Size       int64          `xml:"datasize"`
+	Link       string         `xml:"link"`
+
+	Nested *RecordInfo `xml:"recordxml>recordinfo"`
+}
+
+// ParseRecordInfo parses the <recorditem> payload of a chat history bundle.
+func ParseRecordInfo(raw string) (*RecordInfo, error) {
+	raw = strings.TrimSpace(raw)
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("empty chat history")
+	}
+
+	var record RecordInfo
+	if err := newXMLDecoder(raw).Decode(&record); err != nil {
+		return nil, fmt.Errorf("failed to parse chat history: %w", err)
+	}
+
+	return &record, nil
+}
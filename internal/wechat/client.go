@@ -1,6 +1,7 @@
 package wechat
 
 import (
+	"strconv"
 	"sync"
 
 	"github.com/rs/zerolog"
@@ -147,6 +148,99 @@ func (wc *WechatClient) GetGroupList() []*GroupInfo {
 	}
 }
 
+// AcceptFriendRequest accepts a friend request and returns the new contact.
+func (wc *WechatClient) AcceptFriendRequest(id string) (*UserInfo, error) {
+	if data, err := wc.requestFunc(wc, &Request{
+		Type: ReqAcceptFriendRequest,
+		Data: []string{id},
+	}); err != nil {
+		wc.log.Warn().Msgf("Failed to accept friend request: %v", err)
+		return nil, err
+	} else {
+		info, _ := data.(*UserInfo)
+		return info, nil
+	}
+}
+
+func (wc *WechatClient) RejectFriendRequest(id string) error {
+	if _, err := wc.requestFunc(wc, &Request{
+		Type: ReqRejectFriendRequest,
+		Data: []string{id},
+	}); err != nil {
+		wc.log.Warn().Msgf("Failed to reject friend request: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// SearchContact looks up a WeChat user by ID or phone number, including
+// users who aren't contacts yet.
+func (wc *WechatClient) SearchContact(query string) *UserInfo {
+	if data, err := wc.requestFunc(wc, &Request{
+		Type: ReqSearchContact,
+		Data: []string{query},
+	}); err != nil {
+		wc.log.Warn().Msgf("Failed to search contact: %v", err)
+		return nil
+	} else {
+		info, _ := data.(*UserInfo)
+		return info
+	}
+}
+
+func (wc *WechatClient) SendFriendRequest(wxid, greeting string) error {
+	if _, err := wc.requestFunc(wc, &Request{
+		Type: ReqSendFriendRequest,
+		Data: []string{wxid, greeting},
+	}); err != nil {
+		wc.log.Warn().Msgf("Failed to send friend request: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// JoinGroup accepts a group invite and returns the joined group.
+func (wc *WechatClient) JoinGroup(id string) (*GroupInfo, error) {
+	if data, err := wc.requestFunc(wc, &Request{
+		Type: ReqJoinGroup,
+		Data: []string{id},
+	}); err != nil {
+		wc.log.Warn().Msgf("Failed to join group: %v", err)
+		return nil, err
+	} else {
+		info, _ := data.(*GroupInfo)
+		return info, nil
+	}
+}
+
+// GetRecentChats returns the most recent conversations, newest first.
+func (wc *WechatClient) GetRecentChats(limit int) []*Chat {
+	if data, err := wc.requestFunc(wc, &Request{
+		Type: ReqGetRecentChats,
+		Data: []string{strconv.Itoa(limit)},
+	}); err != nil {
+		wc.log.Warn().Msgf("Failed to get recent chats: %v", err)
+		return nil
+	} else {
+		return data.([]*Chat)
+	}
+}
+
+// GetChatHistory returns the latest messages of a chat, oldest first.
+func (wc *WechatClient) GetChatHistory(chatID string, limit int) []*Event {
+	if data, err := wc.requestFunc(wc, &Request{
+		Type: ReqGetChatHistory,
+		Data: []string{chatID, strconv.Itoa(limit)},
+	}); err != nil {
+		wc.log.Warn().Msgf("Failed to get chat history: %v", err)
+		return nil
+	} else {
+		return data.([]*Event)
+	}
+}
+
 func (wc *WechatClient) SendEvent(event *Event) (*Event, error) {
 	if data, err := wc.requestFunc(wc, &Request{
 		Type: ReqEvent,
@@ -62,6 +62,9 @@ type ReplyInfo struct {
 	Content   string `json:"content"`
 }
 
+// MentionAll is the mention which notifies every member of a group (@所有人).
+const MentionAll = "notify@all"
+
 type AppData struct {
 	Title       string `json:"title,omitempty"`
 	Description string `json:"desc,omitempty"`
@@ -80,9 +83,46 @@ type LocationData struct {
 }
 
 type BlobData struct {
-	Name   string `json:"name,omitempty"`
-	Mime   string `json:"mime,omitempty"`
-	Binary []byte `json:"binary"`
+	Name     string `json:"name,omitempty"`
+	Mime     string `json:"mime,omitempty"`
+	Duration int64  `json:"duration,omitempty"` // milliseconds, voice only
+	Binary   []byte `json:"binary"`
+}
+
+// FriendRequestData is an incoming friend request. The ID is assigned by the
+// agent and used to accept or reject the request later on.
+type FriendRequestData struct {
+	ID       string `json:"id"`
+	UserID   string `json:"user_id,omitempty"`
+	Nickname string `json:"nickname,omitempty"`
+	Avatar   string `json:"avatar,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// GroupInviteData is an invitation of the logged in user to a group. Like
+// friend requests, the ID is assigned by the agent.
+type GroupInviteData struct {
+	ID          string `json:"id"`
+	GroupID     string `json:"group_id,omitempty"`
+	GroupName   string `json:"group_name,omitempty"`
+	Inviter     string `json:"inviter,omitempty"`
+	InviterName string `json:"inviter_name,omitempty"`
+	Avatar      string `json:"avatar,omitempty"`
+}
+
+type SystemType int
+
+const (
+	SystemUnknown SystemType = iota
+	SystemGroupNickname
+)
+
+// SystemData carries the structured part of system events, the text shown
+// to users is still in Event.Content.
+type SystemData struct {
+	Type     SystemType `json:"type"`
+	Member   string     `json:"member,omitempty"`
+	Nickname string     `json:"nickname,omitempty"`
 }
 
 func (o *Message) UnmarshalJSON(data []byte) error {
@@ -130,7 +170,9 @@ func (o *Request) UnmarshalJSON(data []byte) error {
 			return err
 		}
 		o.Data = event
-	case ReqGetUserInfo, ReqGetGroupInfo, ReqGetGroupMembers, ReqGetGroupMemberNickname:
+	case ReqGetUserInfo, ReqGetGroupInfo, ReqGetGroupMembers, ReqGetGroupMemberNickname,
+		ReqAcceptFriendRequest, ReqRejectFriendRequest, ReqSearchContact, ReqSendFriendRequest,
+		ReqJoinGroup, ReqGetRecentChats, ReqGetChatHistory:
 		var params []string
 		if err := json.Unmarshal(rawMsg, &params); err != nil {
 			return err
@@ -174,13 +216,13 @@ func (o *Response) UnmarshalJSON(data []byte) error {
 			return err
 		}
 		o.Data = status
-	case RespGetSelf, RespGetUserInfo:
+	case RespGetSelf, RespGetUserInfo, RespAcceptFriendRequest, RespSearchContact:
 		var info *UserInfo
 		if err := json.Unmarshal(rawMsg, &info); err != nil {
 			return err
 		}
 		o.Data = info
-	case RespGetGroupInfo:
+	case RespGetGroupInfo, RespJoinGroup:
 		var info *GroupInfo
 		if err := json.Unmarshal(rawMsg, &info); err != nil {
 			return err
@@ -210,6 +252,18 @@ func (o *Response) UnmarshalJSON(data []byte) error {
 			return err
 		}
 		o.Data = groups
+	case RespGetRecentChats:
+		var chats []*Chat
+		if err := json.Unmarshal(rawMsg, &chats); err != nil {
+			return err
+		}
+		o.Data = chats
+	case RespGetChatHistory:
+		var events []*Event
+		if err := json.Unmarshal(rawMsg, &events); err != nil {
+			return err
+		}
+		o.Data = events
 	default:
 	}
 
@@ -251,6 +305,27 @@ func (o *Event) UnmarshalJSON(data []byte) error {
 			return err
 		}
 		o.Data = app
+	case EventSystem:
+		// Plain text only system events have no data.
+		var system *SystemData
+		if len(rawMsg) > 0 {
+			if err := json.Unmarshal(rawMsg, &system); err != nil {
+				return err
+			}
+		}
+		o.Data = system
+	case EventFriendRequest:
+		var request *FriendRequestData
+		if err := json.Unmarshal(rawMsg, &request); err != nil {
+			return err
+		}
+		o.Data = request
+	case EventGroupInvite:
+		var invite *GroupInviteData
+		if err := json.Unmarshal(rawMsg, &invite); err != nil {
+			return err
+		}
+		o.Data = invite
 	}
 
 	return nil
@@ -274,6 +349,13 @@ const (
 	ReqGetGroupMemberNickname
 	ReqGetFriendList
 	ReqGetGroupList
+	ReqAcceptFriendRequest
+	ReqRejectFriendRequest
+	ReqSearchContact
+	ReqSendFriendRequest
+	ReqJoinGroup
+	ReqGetRecentChats
+	ReqGetChatHistory
 )
 
 const (
@@ -289,6 +371,13 @@ const (
 	RespGetGroupMemberNickname
 	RespGetFriendList
 	RespGetGroupList
+	RespAcceptFriendRequest
+	RespRejectFriendRequest
+	RespSearchContact
+	RespSendFriendRequest
+	RespJoinGroup
+	RespGetRecentChats
+	RespGetChatHistory
 )
 
 const (
@@ -309,6 +398,8 @@ const (
 	EventRevoke
 	EventVoIP
 	EventSystem
+	EventFriendRequest
+	EventGroupInvite
 )
 
 type MessageType int
@@ -352,6 +443,20 @@ func (t RequestType) String() string {
 		return "get_friend_list"
 	case ReqGetGroupList:
 		return "get_group_list"
+	case ReqAcceptFriendRequest:
+		return "accept_friend_request"
+	case ReqRejectFriendRequest:
+		return "reject_friend_request"
+	case ReqSearchContact:
+		return "search_contact"
+	case ReqSendFriendRequest:
+		return "send_friend_request"
+	case ReqJoinGroup:
+		return "join_group"
+	case ReqGetRecentChats:
+		return "get_recent_chats"
+	case ReqGetChatHistory:
+		return "get_chat_history"
 	default:
 		return "unknown"
 	}
@@ -385,6 +490,20 @@ func (t ResponseType) String() string {
 		return "get_friend_list"
 	case RespGetGroupList:
 		return "get_group_list"
+	case RespAcceptFriendRequest:
+		return "accept_friend_request"
+	case RespRejectFriendRequest:
+		return "reject_friend_request"
+	case RespSearchContact:
+		return "search_contact"
+	case RespSendFriendRequest:
+		return "send_friend_request"
+	case RespJoinGroup:
+		return "join_group"
+	case RespGetRecentChats:
+		return "get_recent_chats"
+	case RespGetChatHistory:
+		return "get_chat_history"
 	default:
 		return "unknown"
 	}
@@ -431,6 +550,10 @@ func (t EventType) String() string {
 		return "voip"
 	case EventSystem:
 		return "system"
+	case EventFriendRequest:
+		return "friend_request"
+	case EventGroupInvite:
+		return "group_invite"
 	default:
 		return "unknown"
 	}
@@ -449,6 +572,9 @@ type GroupInfo struct {
 	Avatar  string   `json:"avatar,omitempty"`
 	Notice  string   `json:"notice,omitempty"`
 	Members []string `json:"members"`
+	// Nicknames maps member IDs to their group nicknames. Agents which
+	// don't report them leave it nil.
+	Nicknames map[string]string `json:"nicknames,omitempty"`
 }
 
 func (er *ErrorResponse) Error() string {
@@ -6,8 +6,11 @@ import (
 	"net/url"
 	"sync"
 
+	"github.com/duo/matrix-wechat/internal/audio"
 	"github.com/duo/matrix-wechat/internal/config"
 	"github.com/duo/matrix-wechat/internal/database"
+	"github.com/duo/matrix-wechat/internal/emoticon"
+	"github.com/duo/matrix-wechat/internal/media"
 	"github.com/duo/matrix-wechat/internal/types"
 	"github.com/duo/matrix-wechat/internal/wechat"
 
@@ -25,6 +28,9 @@ type WechatBridge struct {
 	DB            *database.Database
 	Formatter     *Formatter
 	WechatService *wechat.WechatService
+	Audio         *audio.Transcoder
+	Emoticons     *emoticon.Table
+	MediaConfig   *mautrix.RespMediaConfig
 	ExampleConfig string
 
 	usersByMXID         map[id.UserID]*User
@@ -83,6 +89,25 @@ func (br *WechatBridge) Init() {
 		*br.ZLog,
 	)
 
+	backend, err := audio.NewBackend(br.Config.Bridge.Audio.Backend)
+	if err != nil {
+		br.ZLog.Fatal().Msgf("Failed to set up audio backend: %v", err)
+	}
+	if backend == nil {
+		br.ZLog.Warn().Msgf("ffmpeg not found: WeChat voice messages are sent to Matrix as WAV files and Matrix voice messages are sent to WeChat as files")
+	} else {
+		br.ZLog.Info().Msgf("Using %s audio backend", backend.Name())
+	}
+	br.Audio = audio.NewTranscoder(backend, br.Config.Bridge.Audio.Workers)
+	if !media.FindLottieConverter() {
+		br.ZLog.Warn().Msgf("lottieconverter not found: Lottie stickers from Matrix are sent to WeChat as files")
+	}
+
+	br.Emoticons, err = emoticon.Load(br.Config.Bridge.Emoticons.Overrides)
+	if err != nil {
+		br.ZLog.Fatal().Msgf("Failed to load emoticon table: %v", err)
+	}
+
 	if br.Config.Bridge.HomeserverProxy != "" {
 		if proxyUrl, err := url.Parse(br.Config.Bridge.HomeserverProxy); err != nil {
 			br.ZLog.Warn().Msgf("Failed to parse bridge.hs_proxy: %v", err)
@@ -94,8 +119,31 @@ func (br *WechatBridge) Init() {
 
 func (br *WechatBridge) Start() {
 	br.WaitWebsocketConnected()
+	br.fetchMediaConfig()
 	go br.WechatService.Start()
 	go br.StartUsers()
+	go br.portalArchiveLoop()
+}
+
+func (br *WechatBridge) fetchMediaConfig() {
+	cfg, err := br.Bot.GetMediaConfig()
+	if err != nil {
+		br.ZLog.Warn().Msgf("Failed to fetch media config: %v", err)
+		return
+	}
+	br.MediaConfig = cfg
+}
+
+// MaxMediaSize returns the size limit of media uploaded to Matrix, 0 if there's none.
+func (br *WechatBridge) MaxMediaSize() int64 {
+	limit := br.Config.Bridge.Media.MaxSize
+	if br.MediaConfig != nil && br.MediaConfig.UploadSize > 0 {
+		if limit <= 0 || br.MediaConfig.UploadSize < limit {
+			limit = br.MediaConfig.UploadSize
+		}
+	}
+
+	return limit
 }
 
 func (br *WechatBridge) Stop() {